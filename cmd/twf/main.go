@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 
 	"github.com/wvanlint/twf/internal/config"
@@ -13,6 +14,9 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// Maximum number of directories read concurrently in the background.
+const loaderWorkers = 8
+
 func main() {
 	config := config.GetConfig()
 
@@ -44,13 +48,28 @@ func main() {
 
 	zap.L().Info("Starting twf.")
 
-	tree, err := filetree.InitFileTree(config.Dir)
+	// Build the tree from the paths on stdin if they are piped in.
+	stdinInfo, err := os.Stdin.Stat()
+	if err != nil {
+		panic(err)
+	}
+	readPaths := config.ReadsPaths(stdinInfo.Mode())
+
+	var tree *filetree.FileTree
+	if readPaths {
+		tree, err = filetree.NewPathTree(config.Dir)
+	} else {
+		tree, err = filetree.InitFileTree(config.Dir)
+	}
 	if err != nil {
 		panic(err)
 	}
 	state := state.State{
-		Root:   tree,
-		Cursor: tree,
+		Root:         tree,
+		Cursor:       tree,
+		Loader:       filetree.NewLoader(loaderWorkers),
+		ReadingPaths: readPaths,
+		DiskUsage:    filetree.NewDiskUsage(),
 	}
 
 	var ignore *regexp.Regexp
@@ -63,15 +82,16 @@ func main() {
 	if err := state.AutoExpand(config.AutoexpandDepth, ignore); err != nil {
 		panic(err)
 	}
-	if config.LocatePath != "" {
+	if config.LocatePath != "" && !readPaths {
 		err = state.LocatePath(config.LocatePath)
 		if err != nil {
 			panic(err)
 		}
 	}
+	layout := views.NewLayout(config)
 	views := []terminal.View{
-		views.NewTreeView(config, &state),
-		views.NewPreviewView(config, &state),
+		views.NewTreeView(config, &state, layout),
+		views.NewPreviewView(config, &state, layout),
 		views.NewStatusView(config, &state),
 	}
 
@@ -79,6 +99,31 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	state.Post = t.Post
+	if config.Git {
+		state.RefreshGit(t.Post)
+	}
+	if config.TreeView.DiskUsage {
+		state.DiskUsage.Start(state.Root, config.TreeView.SameDevice, t.Post)
+	}
+	if readPaths {
+		go func() {
+			err := state.ReadPaths(os.Stdin, t.Post, config.AutoexpandDepth, ignore)
+			t.Post(func() error {
+				state.ReadingPaths = false
+				if err != nil {
+					return err
+				}
+				if config.LocatePath != "" {
+					// The path may not have been among the paths read.
+					if err := state.LocatePath(config.LocatePath); err != nil {
+						zap.L().Sugar().Info("Could not locate path: ", err)
+					}
+				}
+				return nil
+			})
+		}()
+	}
 	err = t.StartLoop(config.Keybindings, views)
 	t.Close()
 	if err != nil {
@@ -1,6 +1,6 @@
 module github.com/wvanlint/twf
 
-go 1.13
+go 1.17
 
 require (
 	github.com/stretchr/testify v1.4.0
@@ -8,6 +8,13 @@ require (
 	golang.org/x/crypto v0.0.0-20191011191535-87dc89f01550
 	golang.org/x/sys v0.0.0-20200301040627-c5d0d7b4ec88
 	golang.org/x/text v0.3.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.uber.org/atomic v1.6.0 // indirect
+	go.uber.org/multierr v1.5.0 // indirect
 	golang.org/x/tools v0.0.0-20200708181441-6004c8539734 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v2 v2.2.4 // indirect
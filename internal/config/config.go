@@ -3,6 +3,7 @@ package config
 import (
 	"flag"
 	"fmt"
+	"os"
 	"strings"
 
 	term "github.com/wvanlint/twf/internal/terminal"
@@ -19,15 +20,42 @@ type TwfConfig struct {
 	Keybindings      Keybindings
 	AutoexpandDepth  int
 	AutoexpandIgnore string
+	Git              bool
+	// Whether to read paths from stdin, if set explicitly.
+	Stdin    bool
+	stdinSet bool
+	dirSet   bool
 }
 
+// ReadsPaths returns whether to build the tree from the paths on stdin, given
+// its mode. Unless -stdin is given, paths are only read from pipes and regular
+// files, and only if no root directory is given with -dir.
+func (c *TwfConfig) ReadsPaths(stdin os.FileMode) bool {
+	if c.stdinSet {
+		return c.Stdin
+	}
+	return !c.dirSet && (stdin&os.ModeNamedPipe != 0 || stdin.IsRegular())
+}
+
+// Value of the preview command selecting the built-in previewer.
+const BuiltinPreviewCommand = "builtin"
+
 type PreviewConfig struct {
-	Enabled        bool
-	PreviewCommand string
+	Enabled                bool
+	PreviewCommand         string
+	PreviewDirCommand      string
+	PreviewFallbackCommand string
+	Window                 PreviewWindow
+	Offset                 PreviewOffset
 }
 
 type TreeViewConfig struct {
 	LocateCommand string
+	ScrollOff     int
+	DiskUsage     bool
+	SameDevice    bool
+	// Graphics of files from LS_COLORS, or nil.
+	FileColors *FileColors
 }
 
 type GraphicsMapping map[string]*term.Graphics
@@ -55,7 +83,7 @@ func (m GraphicsMapping) Set(s string) error {
 		if err != nil {
 			return err
 		}
-		m[pair[1]] = g
+		m[pair[0]] = g
 	}
 	return nil
 }
@@ -69,6 +97,65 @@ func defaultGraphicsMapping() GraphicsMapping {
 		"tree:cursor": &term.Graphics{
 			Reverse: true,
 		},
+		"tree:loading": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 3},
+		},
+		"tree:brokenLink": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 1},
+		},
+		"tree:permissionDenied": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 1},
+		},
+		"status:error": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 1},
+		},
+		"preview:header": &term.Graphics{
+			Bold: true,
+		},
+		"preview:keyword": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 5},
+		},
+		"preview:builtin": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 3},
+		},
+		"preview:string": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 2},
+		},
+		"preview:number": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 6},
+		},
+		"preview:comment": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 0, Bright: true},
+		},
+		"preview:heading": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 4},
+			Bold:    true,
+		},
+		"preview:line": &term.Graphics{
+			Reverse: true,
+		},
+		"tree:size": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 0, Bright: true},
+		},
+		"git:modified": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 3},
+		},
+		"git:staged": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 2},
+		},
+		"git:untracked": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 1},
+		},
+		"git:ignored": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 0, Bright: true},
+		},
+		"git:conflicted": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 1},
+			Bold:    true,
+		},
+		"git:dirty": &term.Graphics{
+			FgColor: term.Color3Bit{Value: 3},
+		},
 	}
 }
 
@@ -111,21 +198,36 @@ func (ks Keybindings) Set(s string) error {
 
 func defaultKeybindings() Keybindings {
 	return map[string][]string{
-		(&term.Event{term.Rune, 'j'}).HashKey():      []string{"tree:next"},
-		(&term.Event{term.Rune, 'k'}).HashKey():      []string{"tree:prev"},
-		(&term.Event{term.Rune, 'h'}).HashKey():      []string{"tree:parent", "tree:close"},
-		(&term.Event{term.Rune, 'l'}).HashKey():      []string{"tree:open", "tree:next"},
-		(&term.Event{Symbol: term.CtrlJ}).HashKey():  []string{"preview:down"},
-		(&term.Event{Symbol: term.CtrlK}).HashKey():  []string{"preview:up"},
-		(&term.Event{term.Rune, 'o'}).HashKey():      []string{"tree:toggle"},
-		(&term.Event{term.Rune, 'O'}).HashKey():      []string{"tree:toggleAll"},
-		(&term.Event{term.Rune, 'p'}).HashKey():      []string{"tree:parent"},
-		(&term.Event{term.Rune, 'P'}).HashKey():      []string{"tree:parent", "tree:close"},
-		(&term.Event{term.Rune, '/'}).HashKey():      []string{"tree:locateExternal"},
-		(&term.Event{term.Rune, 'q'}).HashKey():      []string{"quit"},
-		(&term.Event{Symbol: term.CtrlC}).HashKey():  []string{"quit"},
-		(&term.Event{Symbol: term.Escape}).HashKey(): []string{"quit"},
-		(&term.Event{Symbol: term.Enter}).HashKey():  []string{"tree:selectPath", "quit"},
+		(&term.Event{Symbol: term.Rune, Value: 'j'}).HashKey():          []string{"tree:next"},
+		(&term.Event{Symbol: term.Rune, Value: 'k'}).HashKey():          []string{"tree:prev"},
+		(&term.Event{Symbol: term.Rune, Value: 'h'}).HashKey():          []string{"tree:parent", "tree:close"},
+		(&term.Event{Symbol: term.Rune, Value: 'l'}).HashKey():          []string{"tree:open", "tree:next"},
+		(&term.Event{Symbol: term.CtrlJ}).HashKey():                     []string{"preview:down"},
+		(&term.Event{Symbol: term.CtrlK}).HashKey():                     []string{"preview:up"},
+		(&term.Event{Symbol: term.Down, Mod: term.ModShift}).HashKey():  []string{"preview:pageDown"},
+		(&term.Event{Symbol: term.Up, Mod: term.ModShift}).HashKey():    []string{"preview:pageUp"},
+		(&term.Event{Symbol: term.Left, Mod: term.ModShift}).HashKey():  []string{"preview:left"},
+		(&term.Event{Symbol: term.Right, Mod: term.ModShift}).HashKey(): []string{"preview:right"},
+		(&term.Event{Symbol: term.Rune, Value: 'w'}).HashKey():          []string{"preview:toggleWrap"},
+		(&term.Event{Symbol: term.Rune, Value: 's'}).HashKey():          []string{"tree:toggleSortBySize"},
+		(&term.Event{Symbol: term.CtrlE}).HashKey():                     []string{"tree:scrollDown"},
+		(&term.Event{Symbol: term.CtrlY}).HashKey():                     []string{"tree:scrollUp"},
+		(&term.Event{Symbol: term.CtrlSlash}).HashKey():                 []string{"preview:toggle"},
+		(&term.Event{Symbol: term.CtrlR}).HashKey():                     []string{"git:refresh"},
+		(&term.Event{Symbol: term.Rune, Value: 'o'}).HashKey():          []string{"tree:toggle"},
+		(&term.Event{Symbol: term.Rune, Value: 'O'}).HashKey():          []string{"tree:toggleAll"},
+		(&term.Event{Symbol: term.Rune, Value: 'p'}).HashKey():          []string{"tree:parent"},
+		(&term.Event{Symbol: term.Rune, Value: 'P'}).HashKey():          []string{"tree:parent", "tree:close"},
+		(&term.Event{Symbol: term.Rune, Value: '/'}).HashKey():          []string{"tree:locateExternal"},
+		(&term.Event{Symbol: term.Rune, Value: 'q'}).HashKey():          []string{"quit"},
+		(&term.Event{Symbol: term.CtrlC}).HashKey():                     []string{"quit"},
+		(&term.Event{Symbol: term.Escape}).HashKey():                    []string{"tree:cancelLoading", "quit"},
+		(&term.Event{Symbol: term.Enter}).HashKey():                     []string{"tree:selectPath", "quit"},
+		(&term.Event{Symbol: term.Paste}).HashKey():                     []string{"tree:locate"},
+		(&term.Event{Symbol: term.Home}).HashKey():                      []string{"tree:first"},
+		(&term.Event{Symbol: term.End}).HashKey():                       []string{"tree:last"},
+		(&term.Event{Symbol: term.PgUp}).HashKey():                      []string{"tree:pageUp"},
+		(&term.Event{Symbol: term.PgDown}).HashKey():                    []string{"tree:pageDown"},
 	}
 }
 
@@ -147,7 +249,19 @@ func GetConfig() *TwfConfig {
 		&config.Preview.PreviewCommand,
 		"previewCmd",
 		"cat {}",
-		"Command to create preview of a file.",
+		"Command to create preview of a file. \"builtin\" highlights known file types without external tools.",
+	)
+	flag.StringVar(
+		&config.Preview.PreviewFallbackCommand,
+		"previewFallbackCmd",
+		"",
+		"Command to create preview of a file type unknown to the built-in previewer.",
+	)
+	flag.StringVar(
+		&config.Preview.PreviewDirCommand,
+		"previewDirCmd",
+		"",
+		"Command to create preview of a directory. Empty uses a built-in listing.",
 	)
 	flag.BoolVar(
 		&config.Preview.Enabled,
@@ -155,6 +269,18 @@ func GetConfig() *TwfConfig {
 		true,
 		"Enable/disable previews.",
 	)
+	config.Preview.Window = *defaultPreviewWindow()
+	flag.Var(
+		&config.Preview.Window,
+		"previewWindow",
+		"Layout of the preview window, e.g. right:50%, bottom:40%:noborder or up:hidden.",
+	)
+	config.Preview.Offset = *defaultPreviewOffset()
+	flag.Var(
+		&config.Preview.Offset,
+		"previewOffset",
+		"Line to scroll the preview to when a line is located with path:line, e.g. {line}-5.",
+	)
 	flag.IntVar(
 		&config.AutoexpandDepth,
 		"autoexpandDepth",
@@ -167,31 +293,116 @@ func GetConfig() *TwfConfig {
 		"",
 		"Regular expression matching relative paths to ignore when auto-expanding directories at startup.",
 	)
+	flag.BoolVar(
+		&config.Stdin,
+		"stdin",
+		false,
+		"Build the tree from the paths on stdin. By default, they are read if stdin is a pipe or a file and -dir isn't given.",
+	)
+	flag.BoolVar(
+		&config.Git,
+		"git",
+		false,
+		"Show the git status of files.",
+	)
 	flag.StringVar(
 		&config.TreeView.LocateCommand,
 		"locateCmd",
 		"fzf",
 		"External command which returns a path to locate.",
 	)
+	flag.IntVar(
+		&config.TreeView.ScrollOff,
+		"scrolloff",
+		0,
+		"Minimal number of lines to keep above and below the cursor in the tree view.",
+	)
+	flag.BoolVar(
+		&config.TreeView.DiskUsage,
+		"du",
+		false,
+		"Compute the cumulative sizes of directories in the background at startup.",
+	)
+	flag.BoolVar(
+		&config.TreeView.SameDevice,
+		"xdev",
+		false,
+		"Skip directories on other filesystems when computing sizes of directories.",
+	)
+	lsColors := flag.Bool(
+		"lsColors",
+		true,
+		"Color files in the tree according to LS_COLORS.",
+	)
 	flag.Float64Var(
 		&config.Terminal.Height,
 		"height",
 		1.0,
 		"Proportion of the vertical space to take up.",
 	)
+	flag.StringVar(
+		&config.Terminal.Record,
+		"record",
+		"",
+		"File to record the input of the session to, for bug reports.",
+	)
+	flag.StringVar(
+		&config.Terminal.Replay,
+		"replay",
+		"",
+		"File to replay a recorded session from.",
+	)
+	flag.BoolVar(
+		&config.Terminal.Headless,
+		"headless",
+		false,
+		"Replay the session of -replay without a terminal.",
+	)
+	flag.BoolVar(
+		&config.Terminal.KittyKeyboard,
+		"kittyKeyboard",
+		false,
+		"Enable the kitty keyboard protocol to distinguish keys such as ctrl-i and tab.",
+	)
 	config.Keybindings = defaultKeybindings()
 	flag.Var(
 		config.Keybindings,
 		"bind",
 		"Keybindings for command sequences.",
 	)
-	config.Graphics = defaultGraphicsMapping()
+	theme := Theme("dark")
+	if os.Getenv("NO_COLOR") != "" {
+		theme = Theme("monochrome")
+	}
+	flag.Var(
+		&theme,
+		"theme",
+		"Built-in graphics: dark, light, high-contrast or monochrome.",
+	)
+	config.Graphics = NewGraphicsMapping()
 	flag.Var(
 		config.Graphics,
 		"graphics",
-		"Graphics per type of text span.",
+		"Graphics per type of text span, overriding those of the theme.",
 	)
 	flag.Parse()
 	config.LocatePath = flag.Arg(0)
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "stdin":
+			config.stdinSet = true
+		case "dir":
+			config.dirSet = true
+		}
+	})
+	graphics := theme.Graphics()
+	for span, g := range config.Graphics {
+		graphics[span] = g
+	}
+	config.Graphics = graphics
+	config.Terminal.ColorDepth = term.DetectColorDepth(os.Getenv)
+	if value, ok := os.LookupEnv("LS_COLORS"); ok && *lsColors {
+		config.TreeView.FileColors = ParseLSColors(value)
+	}
 	return &config
 }
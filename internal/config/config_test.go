@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+func TestGraphicsMappingSet(t *testing.T) {
+	m := NewGraphicsMapping()
+	err := m.Set("tree:dir::fg#blue:nobold,tree:cursor::underline")
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		GraphicsMapping{
+			"tree:dir": &term.Graphics{
+				FgColor: term.Color3Bit{Value: 4},
+				Off:     term.AttrBold,
+			},
+			"tree:cursor": &term.Graphics{
+				Underline: term.SingleUnderline,
+			},
+		},
+		m,
+	)
+
+	err = m.Set("tree:dir")
+	assert.NotNil(t, err)
+}
+
+func TestReadsPaths(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	assert.Nil(t, err)
+	defer devNull.Close()
+	devNullInfo, err := devNull.Stat()
+	assert.Nil(t, err)
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	defer r.Close()
+	defer w.Close()
+	pipeInfo, err := r.Stat()
+	assert.Nil(t, err)
+
+	c := &TwfConfig{}
+	assert.False(t, c.ReadsPaths(devNullInfo.Mode()))
+	assert.True(t, c.ReadsPaths(pipeInfo.Mode()))
+	assert.True(t, c.ReadsPaths(0644))
+	assert.False(t, c.ReadsPaths(os.ModeSocket))
+
+	c.dirSet = true
+	assert.False(t, c.ReadsPaths(pipeInfo.Mode()))
+	c.Stdin, c.stdinSet = true, true
+	assert.True(t, c.ReadsPaths(devNullInfo.Mode()))
+	c.Stdin = false
+	c.dirSet = false
+	assert.False(t, c.ReadsPaths(pipeInfo.Mode()))
+}
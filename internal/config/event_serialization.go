@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"unicode/utf8"
 
 	term "github.com/wvanlint/twf/internal/terminal"
@@ -28,30 +30,64 @@ func init() {
 		"end":               {Symbol: term.End},
 		"pgup":              {Symbol: term.PgUp},
 		"pgdown":            {Symbol: term.PgDown},
-		"shift-up":          {Symbol: term.ShiftUp},
-		"shift-down":        {Symbol: term.ShiftDown},
-		"shift-left":        {Symbol: term.ShiftLeft},
-		"shift-right":       {Symbol: term.ShiftRight},
-		"ctrl-up":           {Symbol: term.CtrlUp},
-		"ctrl-down":         {Symbol: term.CtrlDown},
-		"ctrl-left":         {Symbol: term.CtrlLeft},
-		"ctrl-right":        {Symbol: term.CtrlRight},
+		"insert":            {Symbol: term.Insert},
+		"delete":            {Symbol: term.Delete},
 		"del":               {Symbol: term.Del},
+		"paste":             {Symbol: term.Paste},
 	}
 	for i := 0; i < 26; i++ {
 		strToEventM["ctrl-"+string(rune('a'+i))] = &term.Event{Symbol: term.EventSymbol(term.CtrlA + i)}
 	}
+	// The control characters of these keys are Tab, Enter and Escape. They
+	// can only be told apart with the kitty keyboard protocol.
+	for _, r := range "im[" {
+		strToEventM["ctrl-"+string(r)] = &term.Event{Symbol: term.Rune, Value: r, Mod: term.ModCtrl}
+	}
+	for i := 0; i < 12; i++ {
+		strToEventM[fmt.Sprint("f", i+1)] = &term.Event{Symbol: term.EventSymbol(term.F1 + i)}
+	}
 	eventHashKeyToStrM = make(map[string]string)
 	for str, event := range strToEventM {
 		eventHashKeyToStrM[event.HashKey()] = str
 	}
 }
 
+// Prefixes of key names for modifiers, in the order in which they are
+// serialized.
+var modifierPrefixes = []struct {
+	prefix string
+	mod    term.Modifier
+}{
+	{"ctrl-", term.ModCtrl},
+	{"alt-", term.ModAlt},
+	{"shift-", term.ModShift},
+	{"meta-", term.ModMeta},
+}
+
 func eventHashKeyToString(key string) string {
 	s, ok := eventHashKeyToStrM[key]
 	if ok {
 		return s
 	}
+	if strings.HasPrefix(key, "@") {
+		// Modifiers are encoded as "@<mod>:<key>".
+		parts := strings.SplitN(key[1:], ":", 2)
+		mod, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return ""
+		}
+		s = eventHashKeyToString(parts[1])
+		if s == "" {
+			return ""
+		}
+		prefix := ""
+		for _, m := range modifierPrefixes {
+			if term.Modifier(mod)&m.mod != 0 {
+				prefix += m.prefix
+			}
+		}
+		return prefix + s
+	}
 	r, size := utf8.DecodeRune([]byte(key))
 	if r != utf8.RuneError && size == len(key) {
 		return key
@@ -59,14 +95,33 @@ func eventHashKeyToString(key string) string {
 	return ""
 }
 
-func parseEvent(s string) (*term.Event, error) {
-	event, ok := strToEventM[s]
-	if ok {
-		return event, nil
-	}
-	r, size := utf8.DecodeRune([]byte(s))
-	if r != utf8.RuneError && size == len(s) {
-		return &term.Event{Symbol: term.Rune, Value: r}, nil
+func parseEvent(name string) (*term.Event, error) {
+	s := name
+	mod := term.Modifier(0)
+	for {
+		event, ok := strToEventM[s]
+		if ok {
+			return &term.Event{Symbol: event.Symbol, Value: event.Value, Mod: mod | event.Mod}, nil
+		}
+		r, size := utf8.DecodeRune([]byte(s))
+		if r != utf8.RuneError && size == len(s) {
+			// Control characters have their own symbols, as in ctrl-alt-a.
+			if event, ok := strToEventM["ctrl-"+s]; ok && mod&term.ModCtrl != 0 {
+				return &term.Event{Symbol: event.Symbol, Value: event.Value, Mod: mod&^term.ModCtrl | event.Mod}, nil
+			}
+			return &term.Event{Symbol: term.Rune, Value: r, Mod: mod}, nil
+		}
+		prefixed := false
+		for _, m := range modifierPrefixes {
+			if strings.HasPrefix(s, m.prefix) && len(s) > len(m.prefix) {
+				s = s[len(m.prefix):]
+				mod |= m.mod
+				prefixed = true
+				break
+			}
+		}
+		if !prefixed {
+			return &term.Event{}, fmt.Errorf("Can't parse event: %s", name)
+		}
 	}
-	return &term.Event{}, fmt.Errorf("Can't parse event: %s", s)
 }
@@ -58,3 +58,33 @@ func TestEventSerializationCtrlKey(t *testing.T) {
 	)
 	assert.Equal(t, ev, ev2)
 }
+
+func TestEventSerializationModifiers(t *testing.T) {
+	cases := map[string]*term.Event{
+		"shift-up":      {Symbol: term.Up, Mod: term.ModShift},
+		"ctrl-right":    {Symbol: term.Right, Mod: term.ModCtrl},
+		"alt-x":         {Symbol: term.Rune, Value: 'x', Mod: term.ModAlt},
+		"ctrl-alt-a":    {Symbol: term.CtrlA, Mod: term.ModAlt},
+		"shift-tab":     {Symbol: term.Tab, Mod: term.ModShift},
+		"ctrl-shift-f5": {Symbol: term.F5, Mod: term.ModCtrl | term.ModShift},
+		"delete":        {Symbol: term.Delete},
+		"f12":           {Symbol: term.F12},
+		"ctrl-i":        {Symbol: term.Rune, Value: 'i', Mod: term.ModCtrl},
+		"ctrl-shift-m":  {Symbol: term.Rune, Value: 'm', Mod: term.ModCtrl | term.ModShift},
+		"ctrl-tab":      {Symbol: term.Tab, Mod: term.ModCtrl},
+		"paste":         {Symbol: term.Paste},
+	}
+	for s, expected := range cases {
+		ev, err := parseEvent(s)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, ev, s)
+		ev2, err := parseEvent(eventHashKeyToString(ev.HashKey()))
+		assert.Nil(t, err)
+		assert.Equal(t, ev, ev2, s)
+	}
+
+	_, err := parseEvent("ctrl-")
+	assert.NotNil(t, err)
+	_, err = parseEvent("hyper-a")
+	assert.NotNil(t, err)
+}
@@ -72,16 +72,19 @@ func color8BitToString(c term.Color8Bit) string {
 
 func parseColor24Bit(s string) (term.Color24Bit, error) {
 	color := term.Color24Bit{}
+	if len(s) != 6 {
+		return color, errors.New("Color hexadecimal string not of length 6.")
+	}
 	rStr, gStr, bStr := s[0:2], s[2:4], s[4:6]
-	r, err := strconv.ParseInt(rStr, 16, 8)
+	r, err := strconv.ParseUint(rStr, 16, 8)
 	if err != nil {
 		return color, err
 	}
-	g, err := strconv.ParseInt(gStr, 16, 8)
+	g, err := strconv.ParseUint(gStr, 16, 8)
 	if err != nil {
 		return color, err
 	}
-	b, err := strconv.ParseInt(bStr, 16, 8)
+	b, err := strconv.ParseUint(bStr, 16, 8)
 	if err != nil {
 		return color, err
 	}
@@ -90,11 +93,13 @@ func parseColor24Bit(s string) (term.Color24Bit, error) {
 }
 
 func color24BitToString(c term.Color24Bit) string {
-	return fmt.Sprintf("%2x%2x%2x", c.R, c.G, c.B)
+	return fmt.Sprintf("%02x%02x%02x", c.R, c.G, c.B)
 }
 
 func parseColor(s string) (term.Color, error) {
-	if color, err := parseColor3Bit(s); err == nil {
+	if s == "default" {
+		return term.DefaultColor{}, nil
+	} else if color, err := parseColor3Bit(s); err == nil {
 		return color, nil
 	} else if color, err := parseColor8Bit(s); err == nil {
 		return color, nil
@@ -106,7 +111,9 @@ func parseColor(s string) (term.Color, error) {
 }
 
 func colorToString(color term.Color) string {
-	if c, ok := color.(term.Color3Bit); ok {
+	if _, ok := color.(term.DefaultColor); ok {
+		return "default"
+	} else if c, ok := color.(term.Color3Bit); ok {
 		return color3BitToString(c)
 	} else if c, ok := color.(term.Color8Bit); ok {
 		return color8BitToString(c)
@@ -117,15 +124,84 @@ func colorToString(color term.Color) string {
 	}
 }
 
+// attributeNames are the names of text attributes in the graphics grammar.
+// An attribute is turned off with a "no" prefix, as in "nobold".
+var attributeNames = []struct {
+	name string
+	attr term.Attributes
+}{
+	{"bold", term.AttrBold},
+	{"dim", term.AttrDim},
+	{"italic", term.AttrItalic},
+	{"underline", term.AttrUnderline},
+	{"blink", term.AttrBlink},
+	{"reverse", term.AttrReverse},
+	{"strikethrough", term.AttrStrikethrough},
+}
+
+var underlineStyleNames = map[term.UnderlineStyle]string{
+	term.SingleUnderline: "underline",
+	term.DoubleUnderline: "doubleunderline",
+	term.CurlyUnderline:  "curlyunderline",
+	term.DottedUnderline: "dottedunderline",
+	term.DashedUnderline: "dashedunderline",
+}
+
+// setAttribute turns an attribute on or off.
+func setAttribute(g *term.Graphics, attr term.Attributes, on bool) {
+	switch attr {
+	case term.AttrBold:
+		g.Bold = on
+	case term.AttrDim:
+		g.Dim = on
+	case term.AttrItalic:
+		g.Italic = on
+	case term.AttrUnderline:
+		if on && g.Underline == term.NoUnderline {
+			g.Underline = term.SingleUnderline
+		} else if !on {
+			g.Underline = term.NoUnderline
+		}
+	case term.AttrBlink:
+		g.Blink = on
+	case term.AttrReverse:
+		g.Reverse = on
+	case term.AttrStrikethrough:
+		g.Strikethrough = on
+	}
+	if on {
+		g.Off &^= attr
+	} else {
+		g.Off |= attr
+	}
+}
+
+func parseAttribute(g *term.Graphics, s string) bool {
+	for style, name := range underlineStyleNames {
+		if s == name {
+			setAttribute(g, term.AttrUnderline, true)
+			g.Underline = style
+			return true
+		}
+	}
+	for _, attribute := range attributeNames {
+		if s == attribute.name {
+			setAttribute(g, attribute.attr, true)
+			return true
+		} else if s == "no"+attribute.name {
+			setAttribute(g, attribute.attr, false)
+			return true
+		}
+	}
+	return false
+}
+
 func parseGraphics(s string) (*term.Graphics, error) {
 	g := term.Graphics{}
 	parts := strings.Split(s, ":")
 	for _, part := range parts {
 		switch {
-		case part == "bold":
-			g.Bold = true
-		case part == "reverse":
-			g.Reverse = true
+		case parseAttribute(&g, part):
 		case strings.HasPrefix(part, "fg#"):
 			color, err := parseColor(part[3:])
 			if err != nil {
@@ -138,6 +214,12 @@ func parseGraphics(s string) (*term.Graphics, error) {
 				return nil, err
 			}
 			g.BgColor = color
+		case strings.HasPrefix(part, "ul#"):
+			color, err := parseColor(part[3:])
+			if err != nil {
+				return nil, err
+			}
+			g.UnderlineColor = color
 		default:
 			return nil, fmt.Errorf("Could not parse graphics: %s", s)
 		}
@@ -147,11 +229,15 @@ func parseGraphics(s string) (*term.Graphics, error) {
 
 func graphicsToString(g *term.Graphics) string {
 	parts := []string{}
-	if g.Bold {
-		parts = append(parts, "bold")
-	}
-	if g.Reverse {
-		parts = append(parts, "reverse")
+	on := g.Attributes()
+	for _, attribute := range attributeNames {
+		if attribute.attr == term.AttrUnderline && on&attribute.attr != 0 {
+			parts = append(parts, underlineStyleNames[g.Underline])
+		} else if on&attribute.attr != 0 {
+			parts = append(parts, attribute.name)
+		} else if g.Off&attribute.attr != 0 {
+			parts = append(parts, "no"+attribute.name)
+		}
 	}
 	if g.FgColor != nil {
 		parts = append(parts, fmt.Sprint("fg#", colorToString(g.FgColor)))
@@ -159,5 +245,8 @@ func graphicsToString(g *term.Graphics) string {
 	if g.BgColor != nil {
 		parts = append(parts, fmt.Sprint("bg#", colorToString(g.BgColor)))
 	}
+	if g.UnderlineColor != nil {
+		parts = append(parts, fmt.Sprint("ul#", colorToString(g.UnderlineColor)))
+	}
 	return strings.Join(parts, ":")
 }
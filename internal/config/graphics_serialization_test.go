@@ -48,4 +48,59 @@ func TestParseGraphics(t *testing.T) {
 		g,
 	)
 	assert.Equal(t, g, g2)
+
+	_, err = parseGraphics("ff8000:bold")
+	assert.NotNil(t, err)
+
+	g, err = parseGraphics("fg#ff8000:bg#default")
+	assert.Nil(t, err)
+	g2, err = parseGraphics(graphicsToString(g))
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		&term.Graphics{
+			FgColor: term.Color24Bit{R: 255, G: 128, B: 0},
+			BgColor: term.DefaultColor{},
+		},
+		g,
+	)
+	assert.Equal(t, g, g2)
+}
+
+func TestParseExtendedGraphics(t *testing.T) {
+	g, err := parseGraphics("italic:dim:curlyunderline:ul#red:blink:strikethrough")
+	assert.Nil(t, err)
+	g2, err := parseGraphics(graphicsToString(g))
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		&term.Graphics{
+			Italic:         true,
+			Dim:            true,
+			Underline:      term.CurlyUnderline,
+			UnderlineColor: term.Color3Bit{Value: 1},
+			Blink:          true,
+			Strikethrough:  true,
+		},
+		g,
+	)
+	assert.Equal(t, g, g2)
+
+	g, err = parseGraphics("bold:nobold:noreverse:underline:nounderline")
+	assert.Nil(t, err)
+	g2, err = parseGraphics(graphicsToString(g))
+	assert.Nil(t, err)
+	assert.Equal(
+		t,
+		&term.Graphics{
+			Off: term.AttrBold | term.AttrReverse | term.AttrUnderline,
+		},
+		g,
+	)
+	assert.Equal(t, g, g2)
+
+	_, err = parseGraphics("fg#12345")
+	assert.NotNil(t, err)
+	_, err = parseGraphics("overline")
+	assert.NotNil(t, err)
 }
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"io/fs"
+	"strings"
+
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+// File is a file whose graphics can be looked up in FileColors.
+type File interface {
+	Name() string
+	// Mode returns the mode of the file itself, and TargetMode that of the
+	// target of a symbolic link.
+	Mode() fs.FileMode
+	TargetMode() fs.FileMode
+	BrokenLink() bool
+}
+
+// FileColors are the graphics of files by type and name, as in the LS_COLORS
+// environment variable of ls, e.g. "di=01;34:ln=01;36:*.tar=01;31".
+type FileColors struct {
+	// Graphics per type of file, with the keys of dircolors such as "di",
+	// "ln" or "ex".
+	Types map[string]*term.Graphics
+	// Graphics per suffix of file names, with keys such as "*.tar".
+	Suffixes []SuffixColor
+	// Whether symbolic links are shown like their targets, with "ln=target".
+	LinkTarget bool
+}
+
+type SuffixColor struct {
+	Suffix   string
+	Graphics *term.Graphics
+}
+
+// ParseLSColors parses the value of LS_COLORS. Entries which can't be parsed
+// are ignored, as by ls.
+func ParseLSColors(s string) *FileColors {
+	c := &FileColors{Types: map[string]*term.Graphics{}}
+	for _, entry := range strings.Split(s, ":") {
+		pair := strings.SplitN(entry, "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			continue
+		}
+		key, value := pair[0], pair[1]
+		switch {
+		case strings.HasPrefix(key, "*"):
+			c.Suffixes = append(c.Suffixes, SuffixColor{key[1:], term.ParseSGR(value)})
+		case key == "ln" && value == "target":
+			c.LinkTarget = true
+		case len(key) == 2:
+			c.Types[key] = term.ParseSGR(value)
+		}
+	}
+	return c
+}
+
+// Lookup returns the graphics of a file, or nil if it has none.
+func (c *FileColors) Lookup(f File) *term.Graphics {
+	mode := f.Mode()
+	if c.LinkTarget && !f.BrokenLink() {
+		mode = f.TargetMode()
+	}
+	for _, key := range fileTypeKeys(mode, f.BrokenLink()) {
+		if g, ok := c.Types[key]; ok {
+			return g
+		}
+	}
+	if !mode.IsRegular() {
+		return nil
+	}
+	if g := c.lookupSuffix(f.Name()); g != nil {
+		return g
+	}
+	return c.Types["fi"]
+}
+
+// lookupSuffix returns the graphics of the last suffix matching the name,
+// preferring suffixes which match with the same case.
+func (c *FileColors) lookupSuffix(name string) *term.Graphics {
+	for i := len(c.Suffixes) - 1; i >= 0; i-- {
+		if strings.HasSuffix(name, c.Suffixes[i].Suffix) {
+			return c.Suffixes[i].Graphics
+		}
+	}
+	lowerName := strings.ToLower(name)
+	for i := len(c.Suffixes) - 1; i >= 0; i-- {
+		if strings.HasSuffix(lowerName, strings.ToLower(c.Suffixes[i].Suffix)) {
+			return c.Suffixes[i].Graphics
+		}
+	}
+	return nil
+}
+
+// fileTypeKeys returns the keys of LS_COLORS which apply to a file type, most
+// specific first. Regular files without special permissions have no keys,
+// since they are looked up by suffix.
+func fileTypeKeys(mode fs.FileMode, brokenLink bool) []string {
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		if brokenLink {
+			return []string{"or", "ln"}
+		}
+		return []string{"ln"}
+	case mode.IsDir():
+		sticky, otherWritable := mode&fs.ModeSticky != 0, mode&0002 != 0
+		switch {
+		case sticky && otherWritable:
+			return []string{"tw", "ow", "st", "di"}
+		case otherWritable:
+			return []string{"ow", "di"}
+		case sticky:
+			return []string{"st", "di"}
+		}
+		return []string{"di"}
+	case mode&fs.ModeNamedPipe != 0:
+		return []string{"pi"}
+	case mode&fs.ModeSocket != 0:
+		return []string{"so"}
+	case mode&fs.ModeCharDevice != 0:
+		return []string{"cd"}
+	case mode&fs.ModeDevice != 0:
+		return []string{"bd"}
+	}
+	keys := []string{}
+	if mode&fs.ModeSetuid != 0 {
+		keys = append(keys, "su")
+	}
+	if mode&fs.ModeSetgid != 0 {
+		keys = append(keys, "sg")
+	}
+	if mode&0111 != 0 {
+		keys = append(keys, "ex")
+	}
+	return keys
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+type testFile struct {
+	name       string
+	mode       fs.FileMode
+	targetMode fs.FileMode
+	brokenLink bool
+}
+
+func (f testFile) Name() string            { return f.name }
+func (f testFile) Mode() fs.FileMode       { return f.mode }
+func (f testFile) TargetMode() fs.FileMode { return f.targetMode }
+func (f testFile) BrokenLink() bool        { return f.brokenLink }
+
+func TestParseLSColors(t *testing.T) {
+	c := ParseLSColors("rs=0:di=01;34:ln=01;36:or=40;31;01:ex=01;32:*.tar=01;31:*.TAR=04:invalid::=1")
+	assert.Equal(t, &term.Graphics{Bold: true, FgColor: term.Color3Bit{Value: 4}}, c.Types["di"])
+	assert.Equal(t, &term.Graphics{}, c.Types["rs"])
+	assert.Equal(t, 5, len(c.Types))
+	assert.Equal(
+		t,
+		[]SuffixColor{
+			{".tar", &term.Graphics{Bold: true, FgColor: term.Color3Bit{Value: 1}}},
+			{".TAR", &term.Graphics{Underline: term.SingleUnderline}},
+		},
+		c.Suffixes,
+	)
+	assert.False(t, c.LinkTarget)
+}
+
+func TestLookupLSColors(t *testing.T) {
+	c := ParseLSColors("di=34:tw=42:ln=36:or=31:pi=33:so=35:ex=32:su=41:*.tar=01;31:*.TAR=04:*.gz=1")
+	lookup := func(f testFile) *term.Graphics {
+		if f.targetMode == 0 {
+			f.targetMode = f.mode
+		}
+		return c.Lookup(f)
+	}
+	assert.Equal(t, c.Types["di"], lookup(testFile{name: "dir", mode: fs.ModeDir | 0755}))
+	assert.Equal(t, c.Types["tw"], lookup(testFile{name: "tmp", mode: fs.ModeDir | fs.ModeSticky | 0777}))
+	assert.Equal(t, c.Types["ln"], lookup(testFile{name: "link", mode: fs.ModeSymlink, targetMode: fs.ModeDir}))
+	assert.Equal(t, c.Types["or"], lookup(testFile{name: "link", mode: fs.ModeSymlink, brokenLink: true}))
+	assert.Equal(t, c.Types["pi"], lookup(testFile{name: "fifo", mode: fs.ModeNamedPipe}))
+	assert.Equal(t, c.Types["so"], lookup(testFile{name: "socket", mode: fs.ModeSocket}))
+	assert.Equal(t, c.Types["ex"], lookup(testFile{name: "run.tar", mode: 0755}))
+	assert.Equal(t, c.Types["su"], lookup(testFile{name: "sudo", mode: fs.ModeSetuid | 0755}))
+	assert.Equal(t, c.Suffixes[0].Graphics, lookup(testFile{name: "a.tar", mode: 0644}))
+	assert.Equal(t, c.Suffixes[1].Graphics, lookup(testFile{name: "A.TAR", mode: 0644}))
+	assert.Equal(t, c.Suffixes[2].Graphics, lookup(testFile{name: "A.GZ", mode: 0644}))
+	assert.Nil(t, lookup(testFile{name: "README", mode: 0644}))
+
+	c = ParseLSColors("ln=target:di=34")
+	assert.True(t, c.LinkTarget)
+	assert.Equal(t, c.Types["di"], lookup(testFile{name: "link", mode: fs.ModeSymlink, targetMode: fs.ModeDir}))
+	assert.Nil(t, lookup(testFile{name: "link", mode: fs.ModeSymlink, brokenLink: true}))
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// PreviewOffset is an expression for the line shown at the top of the
+// preview, in the style of the scroll offsets of the --preview-window option
+// of fzf. It is a sum of terms, each of which is a number, {line} for the
+// line given with the located path, or /N for the height of the preview
+// divided by N.
+type PreviewOffset struct {
+	expr  string
+	terms []offsetTerm
+}
+
+type offsetTerm struct {
+	sign  int
+	line  bool
+	value int
+	denom int
+}
+
+var offsetTermRegex = regexp.MustCompile(`^([+-]?)(\{line\}|/\d+|\d+)`)
+
+func defaultPreviewOffset() *PreviewOffset {
+	offset := &PreviewOffset{}
+	if err := offset.Set("{line}-/2"); err != nil {
+		panic(err)
+	}
+	return offset
+}
+
+func (o *PreviewOffset) String() string {
+	return o.expr
+}
+
+func (o *PreviewOffset) Set(s string) error {
+	terms := []offsetTerm{}
+	for rest := s; rest != ""; {
+		m := offsetTermRegex.FindStringSubmatch(rest)
+		if m == nil || (m[1] == "" && len(terms) > 0) {
+			return fmt.Errorf("Unexpected preview offset: %s", s)
+		}
+		rest = rest[len(m[0]):]
+		term := offsetTerm{sign: 1}
+		if m[1] == "-" {
+			term.sign = -1
+		}
+		switch {
+		case m[2] == "{line}":
+			term.line = true
+		case m[2][0] == '/':
+			denom, err := strconv.Atoi(m[2][1:])
+			if err != nil || denom == 0 {
+				return fmt.Errorf("Unexpected preview offset: %s", s)
+			}
+			term.denom = denom
+		default:
+			value, err := strconv.Atoi(m[2])
+			if err != nil {
+				return fmt.Errorf("Unexpected preview offset: %s", s)
+			}
+			term.value = value
+		}
+		terms = append(terms, term)
+	}
+	o.expr = s
+	o.terms = terms
+	return nil
+}
+
+// Scroll returns the index of the first line to show in a preview with the
+// given number of rows, for the 1-based line given with the located path.
+func (o *PreviewOffset) Scroll(line int, rows int) int {
+	top := 0
+	for _, term := range o.terms {
+		value := term.value
+		if term.line {
+			value = line
+		} else if term.denom != 0 {
+			value = rows / term.denom
+		}
+		top += term.sign * value
+	}
+	if top < 1 {
+		return 0
+	}
+	return top - 1
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewOffset(t *testing.T) {
+	o := &PreviewOffset{}
+	assert.Equal(t, 0, o.Scroll(42, 20))
+
+	assert.Nil(t, o.Set("{line}-5"))
+	assert.Equal(t, "{line}-5", o.String())
+	assert.Equal(t, 36, o.Scroll(42, 20))
+	assert.Equal(t, 0, o.Scroll(3, 20))
+
+	assert.Nil(t, o.Set("+{line}+3-/2"))
+	assert.Equal(t, 34, o.Scroll(42, 20))
+
+	assert.Nil(t, o.Set("10"))
+	assert.Equal(t, 9, o.Scroll(42, 20))
+
+	assert.Equal(t, 31, defaultPreviewOffset().Scroll(42, 20))
+
+	assert.NotNil(t, o.Set("{col}"))
+	assert.NotNil(t, o.Set("{line}5"))
+	assert.NotNil(t, o.Set("{line}-"))
+	assert.NotNil(t, o.Set("/0"))
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type PreviewPosition int
+
+const (
+	PreviewRight PreviewPosition = iota
+	PreviewDown
+	PreviewLeft
+	PreviewUp
+)
+
+var fromStrToPreviewPosition = map[string]PreviewPosition{
+	"right":  PreviewRight,
+	"down":   PreviewDown,
+	"bottom": PreviewDown,
+	"left":   PreviewLeft,
+	"up":     PreviewUp,
+	"top":    PreviewUp,
+}
+
+var previewPositionStrs = []string{"right", "down", "left", "up"}
+
+func (p PreviewPosition) String() string {
+	return previewPositionStrs[p]
+}
+
+// Next returns the position following p clockwise.
+func (p PreviewPosition) Next() PreviewPosition {
+	return (p + 1) % PreviewPosition(len(previewPositionStrs))
+}
+
+// PreviewWindow describes the placement of the preview, in the style of the
+// --preview-window option of fzf.
+type PreviewWindow struct {
+	Position PreviewPosition
+	// Size in cells, or in percent of the available space if Percent is set.
+	Size    int
+	Percent bool
+	Hidden  bool
+	Border  bool
+}
+
+func defaultPreviewWindow() *PreviewWindow {
+	return &PreviewWindow{
+		Position: PreviewRight,
+		Size:     50,
+		Percent:  true,
+		Border:   true,
+	}
+}
+
+func (w *PreviewWindow) String() string {
+	parts := []string{w.Position.String()}
+	if w.Percent {
+		parts = append(parts, fmt.Sprint(w.Size, "%"))
+	} else {
+		parts = append(parts, fmt.Sprint(w.Size))
+	}
+	if w.Hidden {
+		parts = append(parts, "hidden")
+	}
+	if w.Border {
+		parts = append(parts, "border")
+	} else {
+		parts = append(parts, "noborder")
+	}
+	return strings.Join(parts, ":")
+}
+
+func (w *PreviewWindow) Set(s string) error {
+	for _, part := range strings.Split(s, ":") {
+		if position, ok := fromStrToPreviewPosition[part]; ok {
+			w.Position = position
+			continue
+		}
+		switch part {
+		case "hidden":
+			w.Hidden = true
+		case "border":
+			w.Border = true
+		case "noborder":
+			w.Border = false
+		default:
+			percent := strings.HasSuffix(part, "%")
+			size, err := strconv.Atoi(strings.TrimSuffix(part, "%"))
+			if err != nil || size <= 0 || (percent && size >= 100) {
+				return fmt.Errorf("Unexpected preview window string: %s", s)
+			}
+			w.Size, w.Percent = size, percent
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreviewWindow(t *testing.T) {
+	w := defaultPreviewWindow()
+	assert.Nil(t, w.Set("bottom:40%"))
+	assert.Equal(
+		t,
+		&PreviewWindow{Position: PreviewDown, Size: 40, Percent: true, Border: true},
+		w,
+	)
+	w2 := &PreviewWindow{}
+	assert.Nil(t, w2.Set(w.String()))
+	assert.Equal(t, w, w2)
+
+	w = defaultPreviewWindow()
+	assert.Nil(t, w.Set("up:10:hidden:noborder"))
+	assert.Equal(
+		t,
+		&PreviewWindow{Position: PreviewUp, Size: 10, Hidden: true},
+		w,
+	)
+	w2 = &PreviewWindow{}
+	assert.Nil(t, w2.Set(w.String()))
+	assert.Equal(t, w, w2)
+
+	assert.NotNil(t, defaultPreviewWindow().Set("right:150%"))
+	assert.NotNil(t, defaultPreviewWindow().Set("sideways"))
+}
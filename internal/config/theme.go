@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// themeGraphics are the graphics of the built-in themes, in the format of
+// -graphics, which override those of the dark theme, the default.
+var themeGraphics = map[string]string{
+	"dark": "",
+	"light": strings.Join([]string{
+		"tree:dir::fg#blue:bold",
+		"tree:loading::fg#130",
+		"preview:builtin::fg#130",
+		"preview:string::fg#28",
+		"preview:number::fg#30",
+		"preview:comment::fg#244",
+		"tree:size::fg#244",
+		"git:modified::fg#130",
+		"git:ignored::fg#244",
+		"git:dirty::fg#130",
+	}, ","),
+	"high-contrast": strings.Join([]string{
+		"tree:dir::fg#brightblue:bold",
+		"tree:cursor::reverse:bold",
+		"tree:loading::fg#brightyellow:bold",
+		"tree:size::fg#white",
+		"tree:brokenLink::fg#brightred:bold",
+		"tree:permissionDenied::fg#brightred:bold",
+		"status:error::fg#brightred:bold",
+		"preview:keyword::fg#brightmagenta:bold",
+		"preview:builtin::fg#brightyellow",
+		"preview:string::fg#brightgreen",
+		"preview:number::fg#brightcyan",
+		"preview:comment::fg#white",
+		"preview:heading::fg#brightblue:bold",
+		"preview:line::reverse:bold",
+		"git:modified::fg#brightyellow:bold",
+		"git:staged::fg#brightgreen:bold",
+		"git:untracked::fg#brightred:bold",
+		"git:ignored::fg#white",
+		"git:conflicted::fg#brightred:bold:underline",
+		"git:dirty::fg#brightyellow:bold",
+	}, ","),
+	"monochrome": strings.Join([]string{
+		"tree:dir::bold",
+		"tree:cursor::reverse",
+		"tree:loading::italic",
+		"tree:size::dim",
+		"tree:brokenLink::strikethrough",
+		"tree:permissionDenied::dim",
+		"status:error::bold",
+		"preview:header::bold",
+		"preview:keyword::bold",
+		"preview:builtin::italic",
+		"preview:string::italic",
+		"preview:number::italic",
+		"preview:comment::dim",
+		"preview:heading::bold:underline",
+		"preview:line::reverse",
+		"git:modified::bold",
+		"git:staged::bold",
+		"git:untracked::italic",
+		"git:ignored::dim",
+		"git:conflicted::bold:underline",
+		"git:dirty::bold",
+	}, ","),
+}
+
+// Theme is the name of a built-in theme.
+type Theme string
+
+func (t *Theme) String() string {
+	return string(*t)
+}
+
+func (t *Theme) Set(s string) error {
+	if _, ok := themeGraphics[s]; !ok {
+		names := []string{}
+		for name := range themeGraphics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("Unknown theme %s, expected one of %s", s, strings.Join(names, ", "))
+	}
+	*t = Theme(s)
+	return nil
+}
+
+// Graphics returns the graphics of the theme.
+func (t Theme) Graphics() GraphicsMapping {
+	m := defaultGraphicsMapping()
+	if graphics := themeGraphics[string(t)]; graphics != "" {
+		if err := m.Set(graphics); err != nil {
+			panic(err)
+		}
+	}
+	return m
+}
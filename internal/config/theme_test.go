@@ -0,0 +1,28 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThemes(t *testing.T) {
+	defaults := defaultGraphicsMapping()
+	for name := range themeGraphics {
+		theme := Theme("")
+		assert.Nil(t, theme.Set(name))
+		graphics := theme.Graphics()
+		assert.Equal(t, len(defaults), len(graphics), name)
+		if name == "monochrome" {
+			for span, g := range graphics {
+				assert.Nil(t, g.FgColor, span)
+				assert.Nil(t, g.BgColor, span)
+			}
+		}
+	}
+	assert.Equal(t, defaults, Theme("dark").Graphics())
+
+	theme := Theme("dark")
+	assert.NotNil(t, theme.Set("solarized"))
+	assert.Equal(t, Theme("dark"), theme)
+}
@@ -0,0 +1,325 @@
+package filetree
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+type archiveType int
+
+const (
+	noArchive archiveType = iota
+	zipArchive
+	tarArchive
+	tarGzArchive
+)
+
+// archiveKind returns the type of archive of a file based on its name.
+func archiveKind(name string) archiveType {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"), strings.HasSuffix(name, ".jar"):
+		return zipArchive
+	case strings.HasSuffix(name, ".tar"):
+		return tarArchive
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return tarGzArchive
+	}
+	return noArchive
+}
+
+// openArchive returns the contents of the archive of the node as a
+// filesystem.
+func (t *FileTree) openArchive() (fs.FS, error) {
+	switch archiveKind(t.Name()) {
+	case zipArchive:
+		return t.openZip()
+	case tarGzArchive:
+		return newTarFS(func() (io.ReadCloser, error) {
+			f, err := t.Open()
+			if err != nil {
+				return nil, err
+			}
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			return &gzipFile{gz, f}, nil
+		})
+	default:
+		return newTarFS(func() (io.ReadCloser, error) {
+			return t.Open()
+		})
+	}
+}
+
+// openZip reads the directory of a zip archive. Files on disk are closed
+// again and reopened whenever a file in the archive is opened, nested archives
+// are read into memory.
+func (t *FileTree) openZip() (fs.FS, error) {
+	z, closer, err := t.readZip()
+	if err != nil {
+		return nil, err
+	}
+	if closer == nil {
+		return z, nil
+	}
+	closer.Close()
+	return &zipFS{dir: z, open: t.readZip}, nil
+}
+
+// readZip reads the directory of the zip archive of the node. The closer, if
+// not nil, closes the file from which the archive is read.
+func (t *FileTree) readZip() (*zip.Reader, io.Closer, error) {
+	f, err := t.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		return z, nil, err
+	}
+	z, err := zip.NewReader(r, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return z, f, nil
+}
+
+// zipFS is a filesystem for a zip archive on disk. The directory of the
+// archive is kept in memory, and the archive is read again when a file is
+// opened, so that it is only open while files in it are.
+type zipFS struct {
+	dir  *zip.Reader
+	open func() (*zip.Reader, io.Closer, error)
+}
+
+// Stat looks files up in their directories, since opening a file reads its
+// header from the archive.
+func (z *zipFS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return dirInfo("."), nil
+	}
+	if fs.ValidPath(name) {
+		entries, err := fs.ReadDir(z.dir, path.Dir(name))
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.Name() == path.Base(name) {
+				return entry.Info()
+			}
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (z *zipFS) Open(name string) (fs.File, error) {
+	info, err := z.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return z.dir.Open(name)
+	}
+	r, closer, err := z.open()
+	if err != nil {
+		return nil, err
+	}
+	f, err := r.Open(name)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+	return &zipFile{f, closer}, nil
+}
+
+// zipFile is a file in a zip archive on disk, which closes the archive as
+// well.
+type zipFile struct {
+	fs.File
+	archive io.Closer
+}
+
+func (f *zipFile) Close() error {
+	f.File.Close()
+	return f.archive.Close()
+}
+
+type gzipFile struct {
+	*gzip.Reader
+	f io.Closer
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.f.Close()
+}
+
+// tarFS is a filesystem for a tar archive. Tar archives can't be read at
+// random, so only the headers are kept in memory and the archive is read
+// again up to a file when it is opened.
+type tarFS struct {
+	open    func() (io.ReadCloser, error)
+	entries map[string]*tarEntry
+}
+
+type tarEntry struct {
+	info fs.FileInfo
+	// Position of the header of the file in the archive, or -1 for
+	// directories without a header.
+	index    int
+	children []fs.DirEntry
+}
+
+func newTarFS(open func() (io.ReadCloser, error)) (*tarFS, error) {
+	t := &tarFS{
+		open:    open,
+		entries: map[string]*tarEntry{".": {info: dirInfo("."), index: -1}},
+	}
+	rc, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	r := tar.NewReader(rc)
+	for index := 0; ; index++ {
+		header, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		name, ok := tarEntryName(header.Name)
+		if !ok {
+			continue
+		}
+		t.add(name, header.FileInfo(), index)
+	}
+	for _, entry := range t.entries {
+		sort.Slice(entry.children, func(i, j int) bool {
+			return entry.children[i].Name() < entry.children[j].Name()
+		})
+	}
+	return t, nil
+}
+
+// tarEntryName returns the name of a file in the archive as a path within
+// the filesystem.
+func tarEntryName(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+	return name, name != "." && fs.ValidPath(name)
+}
+
+// add adds a file to the archive, creating missing parent directories. A file
+// which occurs more than once is replaced by its last occurrence, as when
+// extracting the archive.
+func (t *tarFS) add(name string, info fs.FileInfo, index int) {
+	dir := path.Dir(name)
+	if entry, ok := t.entries[name]; ok {
+		entry.info = info
+		entry.index = index
+		parent := t.entries[dir]
+		for i, child := range parent.children {
+			if child.Name() == path.Base(name) {
+				parent.children[i] = fs.FileInfoToDirEntry(info)
+			}
+		}
+		return
+	}
+	if _, ok := t.entries[dir]; !ok {
+		t.add(dir, dirInfo(path.Base(dir)), -1)
+	}
+	entry := &tarEntry{info: info, index: index}
+	t.entries[name] = entry
+	parent := t.entries[dir]
+	parent.children = append(parent.children, fs.FileInfoToDirEntry(info))
+}
+
+func (t *tarFS) Stat(name string) (fs.FileInfo, error) {
+	entry, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return entry.info, nil
+}
+
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, ok := t.entries[name]
+	if !ok || !entry.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return append(entry.children[:0:0], entry.children...), nil
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	entry, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if entry.info.IsDir() {
+		return &dirFile{info: entry.info, entries: entry.children}, nil
+	}
+	rc, err := t.open()
+	if err != nil {
+		return nil, err
+	}
+	r := tar.NewReader(rc)
+	for index := 0; ; index++ {
+		if _, err := r.Next(); err != nil {
+			rc.Close()
+			if err == io.EOF {
+				err = fs.ErrNotExist
+			}
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		if index == entry.index {
+			return &tarFile{Reader: r, closer: rc, info: entry.info}, nil
+		}
+	}
+}
+
+type tarFile struct {
+	io.Reader
+	closer io.Closer
+	info   fs.FileInfo
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *tarFile) Close() error {
+	return f.closer.Close()
+}
+
+// dirInfo describes a directory which is implied by the paths in an archive.
+type dirInfo string
+
+func (d dirInfo) Name() string       { return string(d) }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() interface{}   { return nil }
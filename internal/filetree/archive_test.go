@@ -0,0 +1,188 @@
+package filetree
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var archiveFiles = map[string]string{
+	"top":             "top contents",
+	"inner/file":      "file contents",
+	"inner/deep/file": "deep contents",
+}
+
+func writeZip(t *testing.T, path string) {
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, contents := range archiveFiles {
+		fw, err := w.Create(name)
+		assert.Nil(t, err)
+		_, err = fw.Write([]byte(contents))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+}
+
+func writeTarGz(t *testing.T, path string) {
+	f, err := os.Create(path)
+	assert.Nil(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	w := tar.NewWriter(gz)
+	assert.Nil(t, w.WriteHeader(&tar.Header{Name: "./inner/", Mode: 0755, Typeflag: tar.TypeDir}))
+	for name, contents := range archiveFiles {
+		assert.Nil(t, w.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0644, Size: int64(len(contents))}))
+		_, err = w.Write([]byte(contents))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	assert.Nil(t, gz.Close())
+}
+
+func TestArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twf_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeZip(t, filepath.Join(dir, "a.zip"))
+	writeTarGz(t, filepath.Join(dir, "b.tar.gz"))
+
+	root, err := InitFileTree(dir)
+	assert.Nil(t, err)
+	for _, archive := range []string{"a.zip", "b.tar.gz"} {
+		node, err := root.FindPath(archive)
+		assert.Nil(t, err)
+		assert.True(t, node.IsDir())
+		assert.True(t, node.IsArchive())
+		assert.False(t, node.Virtual())
+
+		children, err := node.Children(nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"inner", "top"}, names(children))
+
+		for name, contents := range archiveFiles {
+			node, err := root.FindPath(filepath.Join(archive, name))
+			assert.Nil(t, err)
+			assert.False(t, node.IsDir())
+			assert.True(t, node.Virtual())
+			assert.Equal(t, filepath.Join(dir, archive, name), node.AbsPath)
+
+			f, err := node.Open()
+			assert.Nil(t, err)
+			data, err := ioutil.ReadAll(f)
+			assert.Nil(t, err)
+			assert.Nil(t, f.Close())
+			assert.Equal(t, contents, string(data))
+		}
+	}
+}
+
+func TestTarDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twf_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	f, err := os.Create(filepath.Join(dir, "a.tar"))
+	assert.Nil(t, err)
+	w := tar.NewWriter(f)
+	for _, contents := range []string{"old", "newer"} {
+		assert.Nil(t, w.WriteHeader(&tar.Header{Name: "file", Mode: 0644, Size: int64(len(contents))}))
+		_, err = w.Write([]byte(contents))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, w.Close())
+	assert.Nil(t, f.Close())
+
+	root, err := InitFileTree(dir)
+	assert.Nil(t, err)
+	archive, err := root.FindPath("a.tar")
+	assert.Nil(t, err)
+	children, err := archive.Children(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"file"}, names(children))
+	// The last occurrence wins, as when extracting the archive.
+	assert.Equal(t, int64(5), children[0].Size())
+	r, err := children[0].Open()
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(r)
+	assert.Nil(t, err)
+	assert.Nil(t, r.Close())
+	assert.Equal(t, "newer", string(data))
+}
+
+func TestZipClosed(t *testing.T) {
+	openFiles := func() int {
+		entries, err := ioutil.ReadDir("/proc/self/fd")
+		if err != nil {
+			t.Skip("Open files can't be listed")
+		}
+		return len(entries)
+	}
+	dir, err := ioutil.TempDir("", "twf_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	writeZip(t, filepath.Join(dir, "a.zip"))
+
+	root, err := InitFileTree(dir)
+	assert.Nil(t, err)
+	before := openFiles()
+	node, err := root.FindPath("a.zip/top")
+	assert.Nil(t, err)
+	assert.Equal(t, before, openFiles())
+
+	f, err := node.Open()
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, archiveFiles["top"], string(data))
+	assert.Nil(t, f.Close())
+	assert.Equal(t, before, openFiles())
+}
+
+func TestInvalidArchives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twf_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "x.tar.gz"), nil, 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "notes.zip"), []byte("notes"), 0644))
+	writeZip(t, filepath.Join(dir, "a.zip"))
+
+	root, err := InitFileTree(dir)
+	assert.Nil(t, err)
+	tasks := make(chan func() error, 16)
+	loader := NewLoader(1)
+	assert.Nil(t, loader.ExpandAll(root, func(task func() error) { tasks <- task }))
+	runPosted(t, loader, tasks)
+	for _, name := range []string{"a.zip", "notes.zip", "x.tar.gz"} {
+		node, err := root.FindPath(name)
+		assert.Nil(t, err)
+		assert.False(t, node.Expanded())
+		assert.False(t, node.Loaded())
+	}
+
+	for _, name := range []string{"notes.zip", "x.tar.gz"} {
+		node, err := root.FindPath(name)
+		assert.Nil(t, err)
+		assert.Nil(t, node.Expand())
+		assert.NotNil(t, node.ReadError())
+		assert.False(t, node.IsArchive())
+		assert.False(t, node.IsDir())
+		assert.False(t, node.Expanded())
+	}
+	assertRows(t, root)
+}
+
+func names(nodes []*FileTree) []string {
+	result := []string{}
+	for _, node := range nodes {
+		result = append(result, node.Name())
+	}
+	return result
+}
@@ -0,0 +1,210 @@
+package filetree
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Interval at which computed sizes are merged into the tree.
+const sizeBatchInterval = 100 * time.Millisecond
+
+// DiskUsage computes the cumulative size of directories in the background,
+// like du --apparent-size. Files with several hard links are only counted
+// once. All methods must be called from the goroutine owning the tree.
+type DiskUsage struct {
+	sizes   map[string]int64
+	running bool
+	cancel  chan struct{}
+}
+
+type sizeResult struct {
+	path string
+	size int64
+}
+
+func NewDiskUsage() *DiskUsage {
+	return &DiskUsage{sizes: map[string]int64{}}
+}
+
+// Running returns whether sizes are being computed.
+func (d *DiskUsage) Running() bool {
+	return d.running
+}
+
+// Size returns the size of a file, or the cumulative size of a directory if
+// it has been computed. Archives have the size of their file.
+func (d *DiskUsage) Size(t *FileTree) (int64, bool) {
+	if t.IsArchive() || !t.IsDir() {
+		return t.Size(), true
+	}
+	size, ok := d.sizes[t.AbsPath]
+	return size, ok
+}
+
+// Start computes the sizes of the directories in the tree, discarding the
+// sizes computed before. If sameDevice is set, directories on other
+// filesystems than the root are skipped, like du -x. Sizes are merged into
+// the tree through post, after which the tree is resorted.
+func (d *DiskUsage) Start(root *FileTree, sameDevice bool, post Post) {
+	if d.cancel != nil {
+		close(d.cancel)
+	}
+	cancel := make(chan struct{})
+	d.cancel = cancel
+	d.sizes = map[string]int64{}
+	d.running = true
+
+	results := make(chan sizeResult)
+	w := &sizeWalker{
+		fsys:    root.fsys,
+		results: results,
+		cancel:  cancel,
+		seen:    map[[2]uint64]bool{},
+	}
+	if sameDevice {
+		if info, err := root.fsys.Stat(root.name); err == nil {
+			if id, ok := getFileID(info); ok {
+				w.device = &id.dev
+			}
+		}
+	}
+	go func() {
+		w.walk(root.name, root.AbsPath)
+		close(results)
+	}()
+
+	go func() {
+		batch := []sizeResult{}
+		flush := func(done bool) {
+			if len(batch) == 0 && !done {
+				return
+			}
+			sizes := batch
+			batch = []sizeResult{}
+			post(func() error {
+				if d.cancel != cancel {
+					return nil
+				}
+				for _, r := range sizes {
+					d.sizes[r.path] = r.size
+				}
+				if done {
+					d.running = false
+				}
+				root.Resort()
+				return nil
+			})
+		}
+		ticker := time.NewTicker(sizeBatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case r, ok := <-results:
+				if !ok {
+					flush(true)
+					return
+				}
+				batch = append(batch, r)
+			case <-ticker.C:
+				flush(false)
+			case <-cancel:
+				return
+			}
+		}
+	}()
+}
+
+type sizeWalker struct {
+	fsys    FS
+	results chan<- sizeResult
+	cancel  <-chan struct{}
+	// Device to stay on, if any.
+	device *uint64
+	// Device and inode of files with several hard links which have been
+	// counted.
+	seen map[[2]uint64]bool
+}
+
+// walk returns the cumulative size of a directory and reports the sizes of
+// it and its subdirectories.
+func (w *sizeWalker) walk(name string, absPath string) int64 {
+	select {
+	case <-w.cancel:
+		return 0
+	default:
+	}
+	entries, err := w.fsys.ReadDir(name)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, entry := range entries {
+		childName := path.Join(name, entry.Name())
+		childPath := filepath.Join(absPath, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		id, hasID := getFileID(info)
+		if entry.IsDir() {
+			if w.device != nil && hasID && id.dev != *w.device {
+				continue
+			}
+			total += w.walk(childName, childPath)
+			continue
+		}
+		if info.Mode()&fs.ModeType != 0 {
+			// Symbolic links, devices and the like.
+			continue
+		}
+		if hasID && id.nlink > 1 {
+			key := [2]uint64{id.dev, id.ino}
+			if w.seen[key] {
+				continue
+			}
+			w.seen[key] = true
+		}
+		total += info.Size()
+	}
+	select {
+	case w.results <- sizeResult{absPath, total}:
+	case <-w.cancel:
+	}
+	return total
+}
+
+type fileID struct {
+	dev   uint64
+	ino   uint64
+	nlink uint64
+}
+
+// getFileID returns the device and inode of a file on disk.
+func getFileID(info fs.FileInfo) (fileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{uint64(stat.Dev), uint64(stat.Ino), uint64(stat.Nlink)}, true
+}
+
+// BySize orders by decreasing size, and by ByTypeAndName for equal sizes.
+// Directories whose size is unknown come last.
+func BySize(d *DiskUsage) Order {
+	return func(children []*FileTree) func(i, j int) bool {
+		return func(i, j int) bool {
+			sizeI, okI := d.Size(children[i])
+			sizeJ, okJ := d.Size(children[j])
+			if okI != okJ {
+				return okI
+			}
+			if sizeI != sizeJ {
+				return sizeI > sizeJ
+			}
+			return lessByTypeAndName(children[i], children[j])
+		}
+	}
+}
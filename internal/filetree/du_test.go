@@ -0,0 +1,74 @@
+package filetree
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func computeSizes(t *testing.T, d *DiskUsage, root *FileTree) {
+	tasks := make(chan func() error)
+	d.Start(root, true, func(f func() error) { tasks <- f })
+	for d.Running() {
+		assert.Nil(t, (<-tasks)())
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twf_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "b/c"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "a"), []byte(strings.Repeat("x", 10)), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "b/c/d"), []byte(strings.Repeat("x", 20)), 0644))
+	// Hard links are only counted once.
+	assert.Nil(t, os.Link(filepath.Join(dir, "a"), filepath.Join(dir, "b/e")))
+	assert.Nil(t, os.Symlink("c", filepath.Join(dir, "b/f")))
+
+	root, err := InitFileTree(dir)
+	assert.Nil(t, err)
+	d := NewDiskUsage()
+	computeSizes(t, d, root)
+
+	size, ok := d.Size(root)
+	assert.True(t, ok)
+	assert.Equal(t, int64(30), size)
+	b, err := root.FindPath("b")
+	assert.Nil(t, err)
+	size, ok = d.Size(b)
+	assert.True(t, ok)
+	assert.Equal(t, int64(20), size)
+	a, err := root.FindPath("a")
+	assert.Nil(t, err)
+	size, ok = d.Size(a)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), size)
+}
+
+func TestBySize(t *testing.T) {
+	m := NewMemFS()
+	m.AddFile("small", "x")
+	m.AddFile("big", strings.Repeat("x", 100))
+	m.AddFile("dir/file", strings.Repeat("x", 50))
+	m.AddFile("a.zip", strings.Repeat("x", 70))
+	root, err := InitFileTreeFS(m, ".")
+	assert.Nil(t, err)
+	assert.Nil(t, root.Expand())
+	assert.Equal(t, []string{".", "a.zip", "dir", "big", "small"}, visibleNames(t, root))
+
+	d := NewDiskUsage()
+	root.SetOrder(BySize(d))
+	// Directories without a size come last, archives have the size of
+	// their file.
+	assert.Equal(t, []string{".", "big", "a.zip", "small", "dir"}, visibleNames(t, root))
+	computeSizes(t, d, root)
+	assert.Equal(t, []string{".", "big", "a.zip", "dir", "small"}, visibleNames(t, root))
+	assertRows(t, root)
+
+	root.SetOrder(nil)
+	assert.Equal(t, []string{".", "a.zip", "dir", "big", "small"}, visibleNames(t, root))
+}
@@ -3,39 +3,76 @@ package filetree
 import (
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strings"
 )
 
 type FileTree struct {
-	AbsPath        string
+	AbsPath string
+	// Filesystem containing the node, and the path of the node within it.
+	fsys FS
+	name string
+	// Whether the node is not a file on disk.
+	virtual bool
+	// Whether the node is an archive which is browsed as a directory.
+	archive        bool
 	info           os.FileInfo
 	targetInfo     os.FileInfo
 	parent         *FileTree
 	children       []*FileTree
 	childrenByName map[string]*FileTree
-	expanded       bool
+	// Children in the order in which they were read or added.
+	unsorted []*FileTree
+	expanded bool
+	loading  bool
+	// Error reading the children of the directory, which are then empty.
+	readError error
+	// Order of the stored children, nil for ByTypeAndName.
+	order Order
+	// Index of the node within the children of its parent.
+	index int
+	// Number of visible rows taken up by the node and its descendants.
+	rows int
 }
 
+// InitFileTree creates a tree for a path on disk.
 func InitFileTree(p string) (*FileTree, error) {
 	abs, err := filepath.Abs(p)
 	if err != nil {
 		return nil, err
 	}
-	f, err := os.Open(abs)
-	if err != nil {
-		return nil, err
+	name := strings.TrimPrefix(filepath.ToSlash(abs), "/")
+	if name == "" {
+		name = "."
 	}
-	defer f.Close()
-	info, err := f.Stat()
+	return initFileTree(OS, name, false)
+}
+
+// InitFileTreeFS creates a tree for a path within a filesystem. The nodes of
+// the tree are virtual, with absolute paths relative to the root of the
+// filesystem.
+func InitFileTreeFS(fsys FS, name string) (*FileTree, error) {
+	return initFileTree(fsys, name, true)
+}
+
+func initFileTree(fsys FS, name string, virtual bool) (*FileTree, error) {
+	info, err := fsys.Stat(name)
 	if err != nil {
 		return nil, err
 	}
 	tree := &FileTree{
-		AbsPath: abs,
+		AbsPath: filepath.Join("/", filepath.FromSlash(name)),
+		fsys:    fsys,
+		name:    name,
+		virtual: virtual,
 		info:    info,
+		rows:    1,
 	}
+	tree.archive = !info.IsDir() && archiveKind(info.Name()) != noArchive
 	return tree, nil
 }
 
@@ -44,6 +81,9 @@ func (t *FileTree) Name() string {
 }
 
 func (t *FileTree) IsDir() bool {
+	if t.archive {
+		return true
+	}
 	if t.targetInfo != nil {
 		return t.targetInfo.IsDir()
 	} else {
@@ -51,6 +91,66 @@ func (t *FileTree) IsDir() bool {
 	}
 }
 
+// Size returns the size in bytes of the file, following symbolic links.
+func (t *FileTree) Size() int64 {
+	if t.targetInfo != nil {
+		return t.targetInfo.Size()
+	} else {
+		return t.info.Size()
+	}
+}
+
+// Mode returns the mode of the file, without following symbolic links.
+func (t *FileTree) Mode() os.FileMode {
+	return t.info.Mode()
+}
+
+// TargetMode returns the mode of the file, following symbolic links. For
+// broken symbolic links, this is the mode of the link itself.
+func (t *FileTree) TargetMode() os.FileMode {
+	if t.targetInfo != nil {
+		return t.targetInfo.Mode()
+	} else {
+		return t.info.Mode()
+	}
+}
+
+// BrokenLink returns whether the node is a symbolic link whose target doesn't
+// exist.
+func (t *FileTree) BrokenLink() bool {
+	return t.info.Mode()&os.ModeSymlink != 0 && t.targetInfo == nil
+}
+
+// PermissionDenied returns whether the node is a directory whose children
+// couldn't be read for lack of permission.
+func (t *FileTree) PermissionDenied() bool {
+	return errors.Is(t.readError, fs.ErrPermission)
+}
+
+// ReadError returns the error with which the children of the directory
+// couldn't be read, if any.
+func (t *FileTree) ReadError() error {
+	return t.readError
+}
+
+// IsArchive returns whether the node is an archive whose contents are
+// browsed as a directory.
+func (t *FileTree) IsArchive() bool {
+	return t.archive
+}
+
+// Virtual returns whether the node is not a file on disk, such as a file
+// within an archive. Its AbsPath is then only meaningful to twf.
+func (t *FileTree) Virtual() bool {
+	return t.virtual
+}
+
+// Open opens the file of the node for reading. For archives, this is the
+// archive itself.
+func (t *FileTree) Open() (fs.File, error) {
+	return t.fsys.Open(t.name)
+}
+
 func (t *FileTree) Parent() *FileTree {
 	return t.parent
 }
@@ -60,78 +160,232 @@ func (t *FileTree) Expanded() bool {
 }
 
 func (t *FileTree) Expand() error {
+	if t.expanded {
+		return t.maybeLoadChildren()
+	}
 	t.expanded = true
-	return t.maybeLoadChildren()
+	err := t.maybeLoadChildren()
+	t.updateRows()
+	return err
 }
 
 func (t *FileTree) Collapse() {
+	if !t.expanded {
+		return
+	}
 	t.expanded = false
+	t.updateRows()
+}
+
+// CollapseAll collapses the node and all of its loaded descendants.
+func (t *FileTree) CollapseAll() {
+	t.Collapse()
+	for _, child := range t.children {
+		child.CollapseAll()
+	}
+}
+
+// Loading returns whether the children of the node are being loaded in the
+// background.
+func (t *FileTree) Loading() bool {
+	return t.loading
+}
+
+// Loaded returns whether the children of the node have been read.
+func (t *FileTree) Loaded() bool {
+	return t.children != nil
 }
 
 func (t *FileTree) maybeLoadChildren() error {
-	if t.children != nil {
+	if t.children != nil || t.loading {
 		return nil
 	}
-	t.children = []*FileTree{}
-	t.childrenByName = map[string]*FileTree{}
+	children, err := t.readChildren()
+	t.setChildren(children)
+	t.setReadError(err)
+	return nil
+}
+
+// setReadError records an error reading the children of the node. The node is
+// shown without children instead of failing the command which expanded it.
+// Archives which can't be read are shown as plain files.
+func (t *FileTree) setReadError(err error) {
+	if err == nil {
+		return
+	}
+	t.readError = err
+	if t.archive {
+		t.archive = false
+		t.expanded = false
+		t.updateRows()
+	}
+}
+
+// readChildren reads the children of the node from disk without modifying the
+// tree, so that it can be called outside of the goroutine owning the tree.
+// Broken symbolic links are kept, while links to the node itself or to its
+// ancestors are skipped.
+func (t *FileTree) readChildren() ([]*FileTree, error) {
+	children := []*FileTree{}
 	if !t.IsDir() {
-		return nil
+		return children, nil
 	}
-	f, err := os.Open(t.AbsPath)
-	if err != nil {
-		if errors.Is(err, os.ErrPermission) {
-			return nil
-		} else {
-			return err
+	fsys, dir := t.fsys, t.name
+	if t.archive {
+		archive, err := t.openArchive()
+		if err != nil {
+			return children, err
 		}
+		fsys, dir = WrapFS(archive), "."
 	}
-	defer f.Close()
-	contents, err := f.Readdir(0)
+	entries, err := fsys.ReadDir(dir)
 	if err != nil {
-		return err
+		return children, err
 	}
-	for _, content := range contents {
+	for _, entry := range entries {
+		content, err := entry.Info()
+		if err != nil {
+			continue
+		}
 		childFileTree := &FileTree{
 			AbsPath: filepath.Join(t.AbsPath, content.Name()),
+			fsys:    fsys,
+			name:    path.Join(dir, content.Name()),
+			virtual: t.virtual || t.archive,
 			info:    content,
 			parent:  t,
+			rows:    1,
 		}
 		if content.Mode()&os.ModeSymlink != 0 {
-			if targetInfo, err := os.Stat(childFileTree.AbsPath); err == nil {
-				childFileTree.targetInfo = targetInfo
-				cycleFound := false
-				for node := childFileTree; node.Parent() != nil; node = node.Parent() {
-					if os.SameFile(node.targetInfo, targetInfo) ||
-						os.SameFile(node.info, targetInfo) {
-						cycleFound = true
-						break
-					}
-				}
-				if cycleFound {
+			targetInfo, err := fsys.Stat(childFileTree.name)
+			if err == nil {
+				if childFileTree.isCycle(targetInfo) {
 					continue
 				}
-			} else {
-				continue
+				childFileTree.targetInfo = targetInfo
 			}
 		}
-		t.children = append(t.children, childFileTree)
-		t.childrenByName[childFileTree.Name()] = childFileTree
+		childFileTree.archive = !childFileTree.IsDir() &&
+			archiveKind(content.Name()) != noArchive
+		children = append(children, childFileTree)
 	}
-	return nil
+	return children, nil
 }
 
-func (t *FileTree) Children(order Order) ([]*FileTree, error) {
+// isCycle returns whether the symbolic link of the node points to the node
+// itself or to one of its ancestors.
+func (t *FileTree) isCycle(targetInfo os.FileInfo) bool {
+	target := ""
+	if link, err := t.fsys.Readlink(t.name); err == nil {
+		if strings.HasPrefix(link, "/") {
+			target = path.Clean(strings.TrimPrefix(link, "/"))
+		} else {
+			target = path.Join(path.Dir(t.name), link)
+		}
+	}
+	for node := t; node != nil; node = node.parent {
+		if os.SameFile(node.targetInfo, targetInfo) ||
+			os.SameFile(node.info, targetInfo) ||
+			node.name == target {
+			return true
+		}
+		if node.parent != nil && node.parent.archive {
+			// Ancestors are in another filesystem.
+			break
+		}
+	}
+	return false
+}
+
+// setChildren attaches children read by readChildren, unless the children
+// have been loaded in the meantime.
+func (t *FileTree) setChildren(children []*FileTree) {
+	if t.children != nil {
+		return
+	}
+	t.children = children
+	t.unsorted = append(children[:0:0], children...)
+	t.childrenByName = map[string]*FileTree{}
+	for _, child := range t.children {
+		child.order = t.order
+		t.childrenByName[child.Name()] = child
+	}
+	t.sortChildren()
+	t.updateRows()
+}
+
+// sortChildren sorts the stored children in the order of the node.
+func (t *FileTree) sortChildren() {
+	order := t.order
+	if order == nil {
+		order = ByTypeAndName
+	}
+	sort.SliceStable(t.children, order(t.children))
+	for i, child := range t.children {
+		child.index = i
+	}
+}
+
+// SetOrder changes the order in which the children of the node and its
+// descendants are stored, which is the order of the visible rows. A nil
+// order is ByTypeAndName.
+func (t *FileTree) SetOrder(order Order) {
+	t.order = order
+	if t.children != nil {
+		t.sortChildren()
+	}
+	for _, child := range t.children {
+		child.SetOrder(order)
+	}
+}
+
+// Resort sorts the children of the node and its descendants again, for
+// orders which depend on changing information.
+func (t *FileTree) Resort() {
+	if t.order != nil {
+		t.SetOrder(t.order)
+	}
+}
+
+// sortedChildren returns the children in the given order, or the children as
+// stored if the order is nil. The result must not be modified.
+func (t *FileTree) sortedChildren(order Order) ([]*FileTree, error) {
 	err := t.maybeLoadChildren()
 	if err != nil {
 		return nil, err
 	}
+	if order == nil {
+		return t.children, nil
+	}
 	children := append(t.children[:0:0], t.children...)
+	sort.Slice(children, order(children))
+	return children, nil
+}
+
+// Children returns the children in the given order, or in the order in which
+// they were read if the order is nil.
+func (t *FileTree) Children(order Order) ([]*FileTree, error) {
+	err := t.maybeLoadChildren()
+	if err != nil {
+		return nil, err
+	}
+	children := append(t.unsorted[:0:0], t.unsorted...)
 	if order != nil {
 		sort.Slice(children, order(children))
 	}
 	return children, nil
 }
 
+// OrderedChildren returns the children in the order set with SetOrder, which
+// is the order of the visible rows of the tree.
+func (t *FileTree) OrderedChildren() ([]*FileTree, error) {
+	children, err := t.sortedChildren(nil)
+	if err != nil {
+		return nil, err
+	}
+	return append(children[:0:0], children...), nil
+}
+
 type PathNotFound struct {
 	Path string
 }
@@ -184,7 +438,7 @@ func (t *FileTree) Traverse(visibleOnly bool, order Order, f func(*FileTree, int
 		}
 
 		if !visibleOnly || current.tree.Expanded() {
-			children, err := current.tree.Children(order)
+			children, err := current.tree.sortedChildren(order)
 			if err != nil {
 				return err
 			}
@@ -196,29 +450,61 @@ func (t *FileTree) Traverse(visibleOnly bool, order Order, f func(*FileTree, int
 	return nil
 }
 
+func (t *FileTree) siblingIndex(order Order) ([]*FileTree, int, error) {
+	siblings, err := t.Parent().sortedChildren(order)
+	if err != nil {
+		return nil, 0, err
+	}
+	if order == nil {
+		return siblings, t.index, nil
+	}
+	for i, sibling := range siblings {
+		if sibling == t {
+			return siblings, i, nil
+		}
+	}
+	return nil, 0, PathNotFound{t.AbsPath}
+}
+
+func (t *FileTree) PrevSibling(order Order) (*FileTree, error) {
+	if t.Parent() == nil {
+		return nil, nil
+	}
+	siblings, i, err := t.siblingIndex(order)
+	if err != nil || i == 0 {
+		return nil, err
+	}
+	return siblings[i-1], nil
+}
+
+func (t *FileTree) NextSibling(order Order) (*FileTree, error) {
+	if t.Parent() == nil {
+		return nil, nil
+	}
+	siblings, i, err := t.siblingIndex(order)
+	if err != nil || i == len(siblings)-1 {
+		return nil, err
+	}
+	return siblings[i+1], nil
+}
+
 func (t *FileTree) Prev(visibleOnly bool, order Order) (*FileTree, error) {
 	if t.Parent() == nil {
 		return nil, nil
 	}
-	siblings, err := t.Parent().Children(order)
+	prevSibling, err := t.PrevSibling(order)
 	if err != nil {
 		return nil, err
 	}
-	if t == siblings[0] {
+	if prevSibling == nil {
 		return t.Parent(), nil
 	}
-	var prevSibling *FileTree
-	for i, sibling := range siblings {
-		if sibling == t {
-			prevSibling = siblings[i-1]
-		}
-	}
 	node := prevSibling
 	for {
 		if !node.Expanded() && visibleOnly {
 			return node, nil
 		}
-		children, err := node.Children(order)
+		children, err := node.sortedChildren(order)
 		if err != nil {
 			return nil, err
 		}
@@ -228,12 +514,11 @@ func (t *FileTree) Prev(visibleOnly bool, order Order) (*FileTree, error) {
 			node = children[len(children)-1]
 		}
 	}
-	return nil, nil
 }
 
 func (t *FileTree) Next(visibleOnly bool, order Order) (*FileTree, error) {
 	if t.Expanded() || !visibleOnly {
-		children, err := t.Children(order)
+		children, err := t.sortedChildren(order)
 		if err != nil {
 			return nil, err
 		}
@@ -241,18 +526,14 @@ func (t *FileTree) Next(visibleOnly bool, order Order) (*FileTree, error) {
 			return children[0], nil
 		}
 	}
-	node := t
-	for node.Parent() != nil {
-		siblings, err := node.Parent().Children(order)
+	for node := t; node.Parent() != nil; node = node.Parent() {
+		nextSibling, err := node.NextSibling(order)
 		if err != nil {
 			return nil, err
 		}
-		for i, sibling := range siblings {
-			if sibling == node && i < len(siblings)-1 {
-				return siblings[i+1], nil
-			}
+		if nextSibling != nil {
+			return nextSibling, nil
 		}
-		node = node.Parent()
 	}
 	return nil, nil
 }
@@ -261,10 +542,14 @@ type Order func([]*FileTree) func(i, j int) bool
 
 func ByTypeAndName(children []*FileTree) func(i, j int) bool {
 	return func(i, j int) bool {
-		if children[i].IsDir() != children[j].IsDir() {
-			return children[i].IsDir()
-		} else {
-			return children[i].Name() < children[j].Name()
-		}
+		return lessByTypeAndName(children[i], children[j])
+	}
+}
+
+func lessByTypeAndName(a, b *FileTree) bool {
+	if a.IsDir() != b.IsDir() {
+		return a.IsDir()
+	} else {
+		return a.Name() < b.Name()
 	}
 }
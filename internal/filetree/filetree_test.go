@@ -1,6 +1,7 @@
 package filetree
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -33,6 +34,18 @@ func TestChildren(t *testing.T) {
 	assert.ElementsMatch(t, []string{"dir1", "dir2", "a"}, childrenNames)
 }
 
+func TestOrderedChildren(t *testing.T) {
+	tree, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	// Read in the order of the names, stored with directories first.
+	children, err := tree.Children(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "dir1", "dir2"}, names(children))
+	children, err = tree.OrderedChildren()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dir1", "dir2", "a"}, names(children))
+}
+
 func TestFindPath(t *testing.T) {
 	root, err := InitFileTree("testdata")
 	assert.Nil(t, err)
@@ -107,3 +120,77 @@ func TestPrevNext(t *testing.T) {
 	}
 	assert.Equal(t, []string{"testdata", "dir1", "b", "dir2", "c", "a", "c", "dir2", "b", "dir1", "testdata"}, names)
 }
+
+func TestSiblings(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	node, err := root.FindPath("dir1")
+	assert.Nil(t, err)
+	names := []string{node.Name()}
+	for {
+		next, err := node.NextSibling(ByTypeAndName)
+		assert.Nil(t, err)
+		if next == nil {
+			break
+		}
+		node = next
+		names = append(names, node.Name())
+	}
+	for {
+		prev, err := node.PrevSibling(ByTypeAndName)
+		assert.Nil(t, err)
+		if prev == nil {
+			break
+		}
+		node = prev
+		names = append(names, node.Name())
+	}
+	assert.Equal(t, []string{"dir1", "dir2", "a", "dir2", "dir1"}, names)
+
+	next, err := root.NextSibling(ByTypeAndName)
+	assert.Nil(t, err)
+	assert.Nil(t, next)
+}
+
+func TestInitFileTreeFS(t *testing.T) {
+	m := NewMemFS()
+	m.AddFile("root/dir/file", "contents")
+	m.AddFile("root/a", "")
+	m.AddSymlink("root/dir/link", "../a")
+	m.AddSymlink("root/dir/parent", "..")
+	m.AddSymlink("root/dir/broken", "missing")
+
+	root, err := InitFileTreeFS(m, "root")
+	assert.Nil(t, err)
+	assert.Equal(t, "/root", root.AbsPath)
+	assert.True(t, root.Virtual())
+
+	dir, err := root.FindPath("dir")
+	assert.Nil(t, err)
+	children, err := dir.Children(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"broken", "file", "link"}, names(children))
+	assert.True(t, children[0].BrokenLink())
+	assert.Equal(t, "/root/dir/link", children[2].AbsPath)
+	assert.False(t, children[2].IsDir())
+	assert.False(t, children[2].BrokenLink())
+	assert.True(t, children[2].Mode()&fs.ModeSymlink != 0)
+	assert.True(t, children[2].TargetMode().IsRegular())
+}
+
+func TestPermissionDenied(t *testing.T) {
+	m := NewMemFS()
+	m.AddFile("root/private/file", "")
+	m.SetMode("root/private", fs.ModeDir|0300)
+
+	root, err := InitFileTreeFS(m, "root")
+	assert.Nil(t, err)
+	private, err := root.FindPath("private")
+	assert.Nil(t, err)
+	assert.Nil(t, private.Expand())
+	assert.True(t, private.PermissionDenied())
+	assert.False(t, root.PermissionDenied())
+	children, err := private.Children(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, children)
+}
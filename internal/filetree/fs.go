@@ -0,0 +1,132 @@
+package filetree
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FS is a filesystem a tree can be read from. As in io/fs, paths are
+// slash-separated and unrooted, with "." as the root.
+type FS interface {
+	fs.FS
+	// Stat returns the FileInfo of a file, following symbolic links.
+	Stat(name string) (fs.FileInfo, error)
+	// Lstat returns the FileInfo of a file without following symbolic links.
+	Lstat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of a directory, which describe the entries
+	// themselves rather than the targets of symbolic links.
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// Readlink returns the target of a symbolic link.
+	Readlink(name string) (string, error)
+}
+
+// OS is the filesystem of the operating system, with "." as "/".
+var OS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) path(op string, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join("/", filepath.FromSlash(name)), nil
+}
+
+func (f osFS) Open(name string) (fs.File, error) {
+	p, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (f osFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (f osFS) Lstat(name string) (fs.FileInfo, error) {
+	p, err := f.path("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(p)
+}
+
+func (f osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(p)
+}
+
+func (f osFS) Readlink(name string) (string, error) {
+	p, err := f.path("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(p)
+}
+
+// WrapFS turns a filesystem without symbolic links, such as an archive, into
+// an FS.
+func WrapFS(fsys fs.FS) FS {
+	return wrappedFS{fsys}
+}
+
+type wrappedFS struct {
+	fs.FS
+}
+
+func (w wrappedFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(w.FS, name)
+}
+
+func (w wrappedFS) Lstat(name string) (fs.FileInfo, error) {
+	return fs.Stat(w.FS, name)
+}
+
+func (w wrappedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(w.FS, name)
+}
+
+func (w wrappedFS) Readlink(name string) (string, error) {
+	return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+}
+
+// dirFile is an opened directory of an in-memory filesystem.
+type dirFile struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: fs.ErrInvalid}
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	return append(rest[:0:0], rest...), nil
+}
@@ -0,0 +1,270 @@
+package filetree
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Time to wait for a directory before loading it in the background.
+	syncLoadTimeout = 50 * time.Millisecond
+	// Interval at which to redraw while directories are loading.
+	loadingTickInterval = 100 * time.Millisecond
+)
+
+// Post runs a function on the goroutine owning the tree.
+type Post func(func() error)
+
+// Loader expands directories while reading them in the background, on a
+// bounded number of workers taking directories from a queue. The tree itself
+// is only modified by the functions handed to Post, which keeps the owner of
+// the tree the only writer. All methods must be called from that owner as
+// well.
+type Loader struct {
+	workers int
+	// Directories waiting to be read, and the number of running workers.
+	mu      sync.Mutex
+	queue   []*loadJob
+	running int
+	cancel  chan struct{}
+	// Directories being loaded, and whether they are expanded recursively.
+	pending map[*FileTree]bool
+	active  int32
+}
+
+type loadResult struct {
+	children []*FileTree
+	err      error
+}
+
+// loadJob is a directory to read. Its result is received by the caller of
+// load while it is waiting, and posted to the owner of the tree otherwise.
+type loadJob struct {
+	t       *FileTree
+	cancel  chan struct{}
+	post    func(loadResult)
+	mu      sync.Mutex
+	waiting bool
+	result  chan loadResult
+}
+
+func NewLoader(workers int) *Loader {
+	return &Loader{
+		workers: workers,
+		cancel:  make(chan struct{}),
+		pending: map[*FileTree]bool{},
+	}
+}
+
+// Pending returns the number of directories being loaded.
+func (l *Loader) Pending() int {
+	return len(l.pending)
+}
+
+// Expanding returns the number of directories being loaded to expand them, as
+// opposed to those only read with Load.
+func (l *Loader) Expanding() int {
+	expanding := 0
+	for t := range l.pending {
+		if t.expanded {
+			expanding++
+		}
+	}
+	return expanding
+}
+
+// Expand expands the node. If its children can't be read within a short
+// timeout, they are merged into the tree later on through post.
+func (l *Loader) Expand(t *FileTree, post Post) error {
+	if t.children != nil || t.loading || !t.IsDir() {
+		return t.Expand()
+	}
+	return l.load(t, true, false, syncLoadTimeout, post)
+}
+
+// Load reads the children of the node without expanding it. If they can't be
+// read within a short timeout, they are merged into the tree later on through
+// post.
+func (l *Loader) Load(t *FileTree, post Post) error {
+	if t.children != nil || t.loading || !t.IsDir() {
+		return nil
+	}
+	return l.load(t, false, false, syncLoadTimeout, post)
+}
+
+// ExpandAll recursively expands the node, reading all directories in the
+// background.
+func (l *Loader) ExpandAll(t *FileTree, post Post) error {
+	if t.loading {
+		// Expanded recursively once loaded.
+		l.pending[t] = true
+		t.expanded = true
+		t.updateRows()
+		return nil
+	}
+	if t.children == nil && t.IsDir() {
+		return l.load(t, true, true, 0, post)
+	}
+	if err := t.Expand(); err != nil {
+		return err
+	}
+	return l.expandChildren(t, post)
+}
+
+// expandChildren recursively expands the children of the node. Archives are
+// left collapsed, since each one would have to be read in full.
+func (l *Loader) expandChildren(t *FileTree, post Post) error {
+	for _, child := range t.children {
+		if child.IsArchive() {
+			continue
+		}
+		if err := l.ExpandAll(child, post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cancel stops all pending loads. Directories that haven't been read are
+// collapsed again.
+func (l *Loader) Cancel() {
+	close(l.cancel)
+	l.cancel = make(chan struct{})
+	for t := range l.pending {
+		t.loading = false
+		t.Collapse()
+	}
+	l.pending = map[*FileTree]bool{}
+	atomic.StoreInt32(&l.active, 0)
+}
+
+func (l *Loader) load(t *FileTree, expand bool, recursive bool, timeout time.Duration, post Post) error {
+	if expand {
+		t.expanded = true
+		t.updateRows()
+	}
+
+	job := &loadJob{
+		t:       t,
+		cancel:  l.cancel,
+		waiting: timeout > 0,
+		result:  make(chan loadResult, 1),
+	}
+	job.post = func(r loadResult) {
+		post(func() error {
+			recursive, ok := l.pending[t]
+			if !ok {
+				// Cancelled in the meantime.
+				return nil
+			}
+			delete(l.pending, t)
+			atomic.AddInt32(&l.active, -1)
+			t.loading = false
+			return l.merge(t, r, recursive, post)
+		})
+	}
+	l.enqueue(job)
+
+	if timeout > 0 {
+		select {
+		case r := <-job.result:
+			return l.merge(t, r, recursive, post)
+		case <-time.After(timeout):
+		}
+		job.mu.Lock()
+		job.waiting = false
+		job.mu.Unlock()
+		// The result may have arrived in the meantime.
+		select {
+		case r := <-job.result:
+			return l.merge(t, r, recursive, post)
+		default:
+		}
+	}
+
+	// The posted result is handled once this returns, as it runs on the
+	// owner of the tree as well.
+	t.loading = true
+	l.pending[t] = recursive
+	if atomic.AddInt32(&l.active, 1) == 1 {
+		go l.tick(post)
+	}
+	return nil
+}
+
+// enqueue queues a directory to be read by a worker.
+func (l *Loader) enqueue(job *loadJob) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.queue = append(l.queue, job)
+	l.startWorkers()
+}
+
+// startWorkers starts workers for the queued directories, up to the maximum.
+// l.mu must be held.
+func (l *Loader) startWorkers() {
+	for l.running < l.workers && l.running < len(l.queue) {
+		l.running++
+		go l.work()
+	}
+}
+
+// work reads queued directories until the queue is empty.
+func (l *Loader) work() {
+	for {
+		l.mu.Lock()
+		if len(l.queue) == 0 {
+			l.running--
+			l.mu.Unlock()
+			return
+		}
+		job := l.queue[0]
+		l.queue = l.queue[1:]
+		l.mu.Unlock()
+
+		select {
+		case <-job.cancel:
+			continue
+		default:
+		}
+		children, err := job.t.readChildren()
+		job.finish(loadResult{children, err})
+	}
+}
+
+// finish hands the result of a job to the waiting caller of load, or posts
+// it.
+func (j *loadJob) finish(r loadResult) {
+	j.mu.Lock()
+	waiting := j.waiting
+	if waiting {
+		j.result <- r
+	}
+	j.mu.Unlock()
+	if !waiting {
+		j.post(r)
+	}
+}
+
+func (l *Loader) merge(t *FileTree, r loadResult, recursive bool, post Post) error {
+	t.setChildren(r.children)
+	t.setReadError(r.err)
+	if recursive && t.expanded {
+		return l.expandChildren(t, post)
+	}
+	return nil
+}
+
+// tick posts empty functions while directories are loading, so that the
+// owner of the tree can redraw loading indicators.
+func (l *Loader) tick(post Post) {
+	ticker := time.NewTicker(loadingTickInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if atomic.LoadInt32(&l.active) == 0 {
+			return
+		}
+		post(func() error { return nil })
+	}
+}
@@ -0,0 +1,182 @@
+package filetree
+
+import (
+	"fmt"
+	"io/fs"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runPosted(t *testing.T, loader *Loader, tasks chan func() error) {
+	for loader.Pending() > 0 {
+		select {
+		case task := <-tasks:
+			assert.Nil(t, task())
+		case <-time.After(time.Second):
+			assert.Fail(t, "Timeout")
+			return
+		}
+	}
+}
+
+// occupyWorkers keeps queued directories from being read until the returned
+// function is called.
+func occupyWorkers(l *Loader) func() {
+	l.mu.Lock()
+	l.running += l.workers
+	l.mu.Unlock()
+	return func() {
+		l.mu.Lock()
+		l.running -= l.workers
+		l.startWorkers()
+		l.mu.Unlock()
+	}
+}
+
+func TestLoaderExpandAll(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	tasks := make(chan func() error, 16)
+	loader := NewLoader(2)
+
+	err = loader.ExpandAll(root, func(task func() error) { tasks <- task })
+	assert.Nil(t, err)
+	assert.True(t, root.Expanded())
+	runPosted(t, loader, tasks)
+
+	assert.Equal(t, []string{"testdata", "dir1", "b", "dir2", "c", "a"}, visibleNames(t, root))
+	assertRows(t, root)
+	err = root.Traverse(false, nil, func(node *FileTree, _ int) error {
+		assert.False(t, node.Loading())
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestLoaderExpand(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	tasks := make(chan func() error, 16)
+	loader := NewLoader(1)
+
+	err = loader.Expand(root, func(task func() error) { tasks <- task })
+	assert.Nil(t, err)
+	runPosted(t, loader, tasks)
+	assert.Equal(t, []string{"testdata", "dir1", "dir2", "a"}, visibleNames(t, root))
+}
+
+func TestLoaderLoad(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	tasks := make(chan func() error, 16)
+	loader := NewLoader(1)
+
+	err = loader.Load(root, func(task func() error) { tasks <- task })
+	assert.Nil(t, err)
+	runPosted(t, loader, tasks)
+	assert.True(t, root.Loaded())
+	assert.False(t, root.Expanded())
+	assert.Equal(t, []string{"testdata"}, visibleNames(t, root))
+}
+
+func TestLoaderExpanding(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	tasks := make(chan func() error, 16)
+	post := func(task func() error) { tasks <- task }
+	loader := NewLoader(1)
+
+	release := occupyWorkers(loader)
+	assert.Nil(t, loader.Load(root, post))
+	assert.Equal(t, 1, loader.Pending())
+	// Reading without expanding doesn't count.
+	assert.Equal(t, 0, loader.Expanding())
+	assert.Nil(t, loader.Expand(root, post))
+	assert.Equal(t, 1, loader.Expanding())
+	release()
+	runPosted(t, loader, tasks)
+	assert.Equal(t, 0, loader.Expanding())
+}
+
+func TestLoaderCancel(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	tasks := make(chan func() error, 16)
+	loader := NewLoader(1)
+
+	// Occupy the only worker so that loading can't proceed.
+	release := occupyWorkers(loader)
+	err = loader.ExpandAll(root, func(task func() error) { tasks <- task })
+	assert.Nil(t, err)
+	assert.True(t, root.Loading())
+	assert.Equal(t, 1, loader.Pending())
+
+	loader.Cancel()
+	release()
+	assert.Equal(t, 0, loader.Pending())
+	assert.False(t, root.Loading())
+	assert.False(t, root.Expanded())
+	assertRows(t, root)
+}
+
+func TestLoaderExpandAllWhileLoading(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	tasks := make(chan func() error, 16)
+	post := func(task func() error) { tasks <- task }
+	loader := NewLoader(1)
+
+	release := occupyWorkers(loader)
+	assert.Nil(t, loader.Expand(root, post))
+	assert.True(t, root.Loading())
+	assert.Nil(t, loader.ExpandAll(root, post))
+	release()
+	runPosted(t, loader, tasks)
+
+	assert.Equal(t, []string{"testdata", "dir1", "b", "dir2", "c", "a"}, visibleNames(t, root))
+	assertRows(t, root)
+}
+
+func TestLoaderReadError(t *testing.T) {
+	m := NewMemFS()
+	m.AddFile("private/file", "")
+	m.SetMode("private", fs.ModeDir|0300)
+	root, err := InitFileTreeFS(m, "private")
+	assert.Nil(t, err)
+	tasks := make(chan func() error, 16)
+	loader := NewLoader(1)
+
+	release := occupyWorkers(loader)
+	assert.Nil(t, loader.Expand(root, func(task func() error) { tasks <- task }))
+	release()
+	// Errors are recorded on the node instead of being returned by the task.
+	runPosted(t, loader, tasks)
+	assert.True(t, root.PermissionDenied())
+	assert.NotNil(t, root.ReadError())
+	assert.Equal(t, 1, root.VisibleRows())
+}
+
+func TestLoaderBoundedWorkers(t *testing.T) {
+	m := NewMemFS()
+	for i := 0; i < 100; i++ {
+		m.AddFile(fmt.Sprintf("dir%d/file", i), "")
+	}
+	root, err := InitFileTreeFS(m, ".")
+	assert.Nil(t, err)
+	assert.Nil(t, root.Expand())
+	tasks := make(chan func() error, 128)
+	loader := NewLoader(2)
+
+	before := runtime.NumGoroutine()
+	release := occupyWorkers(loader)
+	assert.Nil(t, loader.ExpandAll(root, func(task func() error) { tasks <- task }))
+	assert.Equal(t, 100, loader.Pending())
+	// Only the ticker runs while all directories are queued.
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before+1)
+	release()
+	runPosted(t, loader, tasks)
+	assert.Equal(t, 201, root.VisibleRows())
+}
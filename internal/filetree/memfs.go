@@ -0,0 +1,178 @@
+package filetree
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Maximum number of symbolic links followed when resolving a path.
+const maxSymlinks = 40
+
+// MemFS is an in-memory FS. Parent directories are created implicitly when
+// adding files.
+type MemFS struct {
+	files map[string]*memFile
+}
+
+// memFile is a file of a MemFS. The data of symbolic links is their target.
+type memFile struct {
+	name     string
+	data     []byte
+	mode     fs.FileMode
+	children []string
+}
+
+func (f *memFile) Name() string       { return f.name }
+func (f *memFile) Size() int64        { return int64(len(f.data)) }
+func (f *memFile) Mode() fs.FileMode  { return f.mode }
+func (f *memFile) ModTime() time.Time { return time.Time{} }
+func (f *memFile) IsDir() bool        { return f.mode.IsDir() }
+func (f *memFile) Sys() interface{}   { return nil }
+
+func NewMemFS() *MemFS {
+	return &MemFS{files: map[string]*memFile{
+		".": {name: ".", mode: fs.ModeDir | 0755},
+	}}
+}
+
+// AddFile adds a regular file with the given contents.
+func (m *MemFS) AddFile(name string, data string) {
+	m.add(name, []byte(data), 0644)
+}
+
+// AddDir adds an empty directory.
+func (m *MemFS) AddDir(name string) {
+	m.add(name, nil, fs.ModeDir|0755)
+}
+
+// AddSymlink adds a symbolic link to the target, which is relative to the
+// directory of the link unless it starts with "/".
+func (m *MemFS) AddSymlink(name string, target string) {
+	m.add(name, []byte(target), fs.ModeSymlink|0777)
+}
+
+// SetMode sets the mode of a file, such as its permissions or the type of a
+// special file. Directories without read permission can't be read, as if
+// twf didn't run as root.
+func (m *MemFS) SetMode(name string, mode fs.FileMode) {
+	if f, ok := m.files[path.Clean(name)]; ok {
+		f.mode = mode
+	}
+}
+
+func (m *MemFS) add(name string, data []byte, mode fs.FileMode) {
+	name = path.Clean(name)
+	if f, ok := m.files[name]; ok {
+		f.data, f.mode = data, mode
+		return
+	}
+	dir := path.Dir(name)
+	if _, ok := m.files[dir]; !ok {
+		m.AddDir(dir)
+	}
+	m.files[name] = &memFile{name: path.Base(name), data: data, mode: mode}
+	parent := m.files[dir]
+	parent.children = append(parent.children, name)
+}
+
+// resolve replaces the symbolic links in a path by their targets. The last
+// element is only replaced if followLast is set.
+func (m *MemFS) resolve(op string, name string, followLast bool) (*memFile, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	parts := strings.Split(name, "/")
+	links := 0
+	for i := 0; i < len(parts); i++ {
+		prefix := path.Join(parts[:i+1]...)
+		f, ok := m.files[prefix]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if f.mode&fs.ModeSymlink == 0 || (i == len(parts)-1 && !followLast) {
+			continue
+		}
+		links++
+		if links > maxSymlinks {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+		}
+		target := string(f.data)
+		if strings.HasPrefix(target, "/") {
+			target = path.Clean(strings.TrimPrefix(target, "/"))
+		} else {
+			target = path.Join(path.Dir(prefix), target)
+		}
+		parts = append(strings.Split(target, "/"), parts[i+1:]...)
+		i = -1
+	}
+	return m.files[path.Join(parts...)], nil
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	f, err := m.resolve("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if f.IsDir() {
+		entries, _ := m.ReadDir(name)
+		return &dirFile{info: f, entries: entries}, nil
+	}
+	return &memOpenFile{bytes.NewReader(f.data), f}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	return m.resolve("stat", name, true)
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.resolve("lstat", name, false)
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := m.resolve("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if f.mode&0444 == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrPermission}
+	}
+	entries := []fs.DirEntry{}
+	for _, child := range f.children {
+		entries = append(entries, fs.FileInfoToDirEntry(m.files[child]))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+func (m *MemFS) Readlink(name string) (string, error) {
+	f, err := m.resolve("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if f.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return string(f.data), nil
+}
+
+type memOpenFile struct {
+	*bytes.Reader
+	info *memFile
+}
+
+func (f *memOpenFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memOpenFile) Close() error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package filetree
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	m.AddFile("dir/file", "contents")
+	m.AddDir("empty")
+	m.AddSymlink("link", "dir")
+	m.AddSymlink("dir/abs", "/dir/file")
+	m.AddSymlink("loop", "loop")
+
+	info, err := m.Stat("dir")
+	assert.Nil(t, err)
+	assert.True(t, info.IsDir())
+
+	info, err = m.Lstat("link")
+	assert.Nil(t, err)
+	assert.Equal(t, fs.ModeSymlink, info.Mode()&fs.ModeSymlink)
+	info, err = m.Stat("link")
+	assert.Nil(t, err)
+	assert.True(t, info.IsDir())
+
+	target, err := m.Readlink("link")
+	assert.Nil(t, err)
+	assert.Equal(t, "dir", target)
+	_, err = m.Readlink("dir")
+	assert.NotNil(t, err)
+
+	entries, err := m.ReadDir("link")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+
+	f, err := m.Open("link/abs")
+	assert.Nil(t, err)
+	data, err := ioutil.ReadAll(f)
+	assert.Nil(t, err)
+	assert.Equal(t, "contents", string(data))
+
+	_, err = m.Stat("loop")
+	assert.NotNil(t, err)
+	_, err = m.Stat("missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
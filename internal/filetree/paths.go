@@ -0,0 +1,111 @@
+package filetree
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// NewPathTree creates a tree for a directory on disk which only contains the
+// paths added with Insert. Directories of the tree are never read from disk.
+func NewPathTree(dir string) (*FileTree, error) {
+	tree, err := InitFileTree(dir)
+	if err != nil {
+		return nil, err
+	}
+	tree.archive = false
+	tree.setChildren([]*FileTree{})
+	return tree, nil
+}
+
+// Insert adds a path and its missing parent directories to a tree created
+// with NewPathTree. Relative paths are relative to the root of the tree. It
+// returns the nodes which were created, parents first.
+func (t *FileTree) Insert(origPath string) ([]*FileTree, error) {
+	p := filepath.Clean(origPath)
+	if filepath.IsAbs(p) {
+		rel, err := filepath.Rel(t.AbsPath, p)
+		if err != nil {
+			return nil, PathNotFound{origPath}
+		}
+		p = rel
+	}
+	p = filepath.ToSlash(p)
+	if p == "." {
+		return nil, nil
+	}
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return nil, PathNotFound{origPath}
+	}
+	if _, err := t.fsys.Lstat(path.Join(t.name, p)); err != nil {
+		return nil, PathNotFound{origPath}
+	}
+
+	created := []*FileTree{}
+	node := t
+	for _, part := range strings.Split(p, "/") {
+		child, ok := node.childrenByName[part]
+		if !ok {
+			var err error
+			child, err = node.newListedChild(part)
+			if err != nil {
+				return created, err
+			}
+			node.insertChild(child)
+			created = append(created, child)
+		}
+		node = child
+	}
+	return created, nil
+}
+
+// newListedChild creates a child for a path added with Insert.
+func (t *FileTree) newListedChild(name string) (*FileTree, error) {
+	child := &FileTree{
+		AbsPath:        filepath.Join(t.AbsPath, name),
+		fsys:           t.fsys,
+		name:           path.Join(t.name, name),
+		virtual:        t.virtual,
+		parent:         t,
+		order:          t.order,
+		children:       []*FileTree{},
+		childrenByName: map[string]*FileTree{},
+		rows:           1,
+	}
+	info, err := t.fsys.Lstat(child.name)
+	if err != nil {
+		return nil, err
+	}
+	child.info = info
+	if info.Mode()&fs.ModeSymlink != 0 {
+		if targetInfo, err := t.fsys.Stat(child.name); err == nil {
+			child.targetInfo = targetInfo
+		}
+	}
+	return child, nil
+}
+
+// insertChild adds a child at its position in the sorted children.
+func (t *FileTree) insertChild(child *FileTree) {
+	t.childrenByName[child.Name()] = child
+	t.unsorted = append(t.unsorted, child)
+	if t.order != nil {
+		t.children = append(t.children, child)
+		t.sortChildren()
+	} else {
+		i := sort.Search(len(t.children), func(i int) bool {
+			return lessByTypeAndName(child, t.children[i])
+		})
+		t.children = append(t.children, nil)
+		copy(t.children[i+1:], t.children[i:])
+		t.children[i] = child
+		for j := i; j < len(t.children); j++ {
+			t.children[j].index = j
+		}
+	}
+	if t.expanded {
+		t.addRows(1)
+	}
+}
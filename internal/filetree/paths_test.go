@@ -0,0 +1,50 @@
+package filetree
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsert(t *testing.T) {
+	root, err := NewPathTree("testdata")
+	assert.Nil(t, err)
+	assert.Nil(t, root.Expand())
+	assert.Equal(t, []string{"testdata"}, visibleNames(t, root))
+
+	created, err := root.Insert("dir2/c")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dir2", "c"}, names(created))
+	assert.True(t, created[0].IsDir())
+	assert.Nil(t, created[0].Expand())
+	assertRows(t, root)
+
+	wd, err := os.Getwd()
+	assert.Nil(t, err)
+	created, err = root.Insert(filepath.Join(wd, "testdata/a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, names(created))
+
+	created, err = root.Insert("dir1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dir1"}, names(created))
+	assert.Equal(t, []string{"testdata", "dir1", "dir2", "c", "a"}, visibleNames(t, root))
+	assertRows(t, root)
+
+	// Directories only contain the inserted paths.
+	children, err := created[0].Children(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, children)
+
+	created, err = root.Insert("dir2/c")
+	assert.Nil(t, err)
+	assert.Empty(t, created)
+
+	_, err = root.Insert("missing/file")
+	assert.NotNil(t, err)
+	_, err = root.Insert("../filetree.go")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"testdata", "dir1", "dir2", "c", "a"}, visibleNames(t, root))
+}
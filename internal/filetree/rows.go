@@ -0,0 +1,80 @@
+package filetree
+
+// The visible rows of a tree are the nodes that are shown when traversing it
+// with visibleOnly set, in the order of Traverse(true, nil, ...). Every node
+// keeps track of the number of visible rows it takes up, so that rows can be
+// looked up without traversing the whole tree. Expanding or collapsing a node
+// only updates the counts of its ancestors.
+
+// updateRows recomputes the number of visible rows of the node and propagates
+// the difference to its ancestors.
+func (t *FileTree) updateRows() {
+	rows := 1
+	if t.expanded {
+		for _, child := range t.children {
+			rows += child.rows
+		}
+	}
+	t.addRows(rows - t.rows)
+}
+
+// addRows adds to the number of visible rows of the node and its ancestors.
+func (t *FileTree) addRows(delta int) {
+	if delta == 0 {
+		return
+	}
+	for node := t; ; node = node.parent {
+		node.rows += delta
+		if node.parent == nil || !node.parent.expanded {
+			break
+		}
+	}
+}
+
+// VisibleRows returns the number of visible rows of the tree, including the
+// node itself.
+func (t *FileTree) VisibleRows() int {
+	return t.rows
+}
+
+// Depth returns the number of ancestors of the node.
+func (t *FileTree) Depth() int {
+	depth := 0
+	for node := t.parent; node != nil; node = node.parent {
+		depth++
+	}
+	return depth
+}
+
+// Row returns the index of the node within the visible rows of its root.
+// The result is only meaningful if all ancestors are expanded.
+func (t *FileTree) Row() int {
+	row := 0
+	for node := t; node.parent != nil; node = node.parent {
+		row++
+		for _, sibling := range node.parent.children[:node.index] {
+			row += sibling.rows
+		}
+	}
+	return row
+}
+
+// RowAt returns the node at the given index within the visible rows of the
+// tree, or nil if the index is out of range.
+func (t *FileTree) RowAt(row int) *FileTree {
+	if row < 0 || row >= t.rows {
+		return nil
+	}
+	node := t
+	for row > 0 {
+		row--
+		for _, child := range node.children {
+			if row < child.rows {
+				node = child
+				break
+			}
+			row -= child.rows
+		}
+	}
+	return node
+}
@@ -0,0 +1,72 @@
+package filetree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func visibleNames(t *testing.T, root *FileTree) []string {
+	names := []string{}
+	err := root.Traverse(true, nil, func(node *FileTree, _ int) error {
+		names = append(names, node.Name())
+		return nil
+	})
+	assert.Nil(t, err)
+	return names
+}
+
+func assertRows(t *testing.T, root *FileTree) {
+	names := visibleNames(t, root)
+	assert.Equal(t, len(names), root.VisibleRows())
+	for i, name := range names {
+		node := root.RowAt(i)
+		assert.Equal(t, name, node.Name())
+		assert.Equal(t, i, node.Row())
+	}
+	assert.Nil(t, root.RowAt(-1))
+	assert.Nil(t, root.RowAt(len(names)))
+}
+
+func TestRows(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	assertRows(t, root)
+
+	assert.Nil(t, root.Expand())
+	assertRows(t, root)
+	assert.Equal(t, 4, root.VisibleRows())
+
+	dir2, err := root.FindPath("dir2")
+	assert.Nil(t, err)
+	assert.Nil(t, dir2.Expand())
+	assertRows(t, root)
+	assert.Equal(t, []string{"testdata", "dir1", "dir2", "c", "a"}, visibleNames(t, root))
+
+	dir1, err := root.FindPath("dir1")
+	assert.Nil(t, err)
+	assert.Nil(t, dir1.Expand())
+	assertRows(t, root)
+	assert.Equal(t, 6, root.VisibleRows())
+
+	root.Collapse()
+	assertRows(t, root)
+	assert.Equal(t, 1, root.VisibleRows())
+
+	assert.Nil(t, root.Expand())
+	assertRows(t, root)
+	assert.Equal(t, 6, root.VisibleRows())
+
+	dir2.Collapse()
+	assertRows(t, root)
+	assert.Equal(t, 5, root.VisibleRows())
+}
+
+func TestDepth(t *testing.T) {
+	root, err := InitFileTree("testdata")
+	assert.Nil(t, err)
+	node, err := root.FindPath("dir1/b")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, node.Depth())
+	assert.Equal(t, 0, root.Depth())
+}
@@ -0,0 +1,156 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Unmodified Kind = iota
+	Modified
+	Staged
+	Untracked
+	Ignored
+	Conflicted
+	// Directories containing modified, staged, untracked or conflicted files.
+	Dirty
+)
+
+var kindStrs = []string{"unmodified", "modified", "staged", "untracked", "ignored", "conflicted", "dirty"}
+
+func (k Kind) String() string {
+	return kindStrs[k]
+}
+
+// Status is the status of the files of a git working tree, as reported by
+// git status.
+type Status struct {
+	// Directory the status was loaded for, and its path within the
+	// repository.
+	dir    string
+	prefix string
+	// Status per path relative to the root of the repository. Paths of
+	// directories which are untracked or ignored as a whole end with "/".
+	files map[string]Kind
+	// Directories containing changes.
+	dirty map[string]bool
+}
+
+// Load runs git status in a directory. It returns an error if the directory
+// is not in a git working tree.
+func Load(dir string) (*Status, error) {
+	prefix, err := run(dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(dir, "status", "--porcelain=v2", "-z", "--ignored")
+	if err != nil {
+		return nil, err
+	}
+	status, err := parse(out)
+	if err != nil {
+		return nil, err
+	}
+	status.dir = dir
+	status.prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/")
+	return status, nil
+}
+
+func run(dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%w %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+// parse parses the output of git status --porcelain=v2 -z.
+func parse(out string) (*Status, error) {
+	s := &Status{files: map[string]Kind{}, dirty: map[string]bool{}}
+	records := strings.Split(out, "\x00")
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+		if record == "" || record[0] == '#' {
+			continue
+		}
+		var kind Kind
+		var p string
+		switch record[0] {
+		case '1', '2', 'u':
+			// Ordinary, renamed or copied, and unmerged entries, with the path
+			// as the last of a fixed number of fields.
+			fieldCount := map[byte]int{'1': 9, '2': 10, 'u': 11}[record[0]]
+			fields := strings.SplitN(record, " ", fieldCount)
+			if len(fields) != fieldCount || len(fields[1]) != 2 {
+				return nil, fmt.Errorf("Unexpected git status entry: %q", record)
+			}
+			p = fields[fieldCount-1]
+			switch {
+			case record[0] == 'u':
+				kind = Conflicted
+			case fields[1][1] != '.':
+				kind = Modified
+			default:
+				kind = Staged
+			}
+			if record[0] == '2' {
+				// Skip the original path of the rename or copy.
+				i++
+			}
+		case '?':
+			kind, p = Untracked, strings.TrimPrefix(record, "? ")
+		case '!':
+			kind, p = Ignored, strings.TrimPrefix(record, "! ")
+		default:
+			return nil, fmt.Errorf("Unexpected git status entry: %q", record)
+		}
+		s.files[p] = kind
+		if kind != Ignored {
+			for dir := path.Dir(strings.TrimSuffix(p, "/")); !s.dirty[dir]; dir = path.Dir(dir) {
+				s.dirty[dir] = true
+				if dir == "." {
+					break
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// Lookup returns the status of a file given by its absolute path.
+func (s *Status) Lookup(absPath string) Kind {
+	rel, err := filepath.Rel(s.dir, absPath)
+	if err != nil {
+		return Unmodified
+	}
+	p := path.Join(s.prefix, filepath.ToSlash(rel))
+	if p == ".." || strings.HasPrefix(p, "../") {
+		return Unmodified
+	}
+	if kind, ok := s.files[p]; ok {
+		return kind
+	}
+	if kind, ok := s.files[p+"/"]; ok {
+		return kind
+	}
+	if s.dirty[p] {
+		return Dirty
+	}
+	// Files in untracked or ignored directories.
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if kind, ok := s.files[dir+"/"]; ok {
+			return kind
+		}
+	}
+	return Unmodified
+}
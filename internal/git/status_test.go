@@ -0,0 +1,68 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	out := strings.Join([]string{
+		"1 .M N... 100644 100644 100644 3f2a 3f2a src/main.go",
+		"1 A. N... 000000 100644 100644 0000 81c5 src/new file.go",
+		"2 R. N... 100644 100644 100644 ab12 ab12 R100 docs/b.md",
+		"docs/a.md",
+		"u UU N... 100644 100644 100644 100644 1111 2222 3333 conflict.txt",
+		"? build/",
+		"! node_modules/",
+		"",
+	}, "\x00")
+	s, err := parse(out)
+	assert.Nil(t, err)
+	s.dir = "/repo/sub"
+	s.prefix = ""
+
+	assert.Equal(t, Modified, s.Lookup("/repo/sub/src/main.go"))
+	assert.Equal(t, Staged, s.Lookup("/repo/sub/src/new file.go"))
+	assert.Equal(t, Staged, s.Lookup("/repo/sub/docs/b.md"))
+	assert.Equal(t, Unmodified, s.Lookup("/repo/sub/docs/a.md"))
+	assert.Equal(t, Conflicted, s.Lookup("/repo/sub/conflict.txt"))
+	assert.Equal(t, Untracked, s.Lookup("/repo/sub/build"))
+	assert.Equal(t, Untracked, s.Lookup("/repo/sub/build/out/x"))
+	assert.Equal(t, Ignored, s.Lookup("/repo/sub/node_modules/a"))
+	assert.Equal(t, Dirty, s.Lookup("/repo/sub/src"))
+	assert.Equal(t, Dirty, s.Lookup("/repo/sub"))
+	assert.Equal(t, Unmodified, s.Lookup("/repo/sub/other.go"))
+	assert.Equal(t, Unmodified, s.Lookup("/elsewhere"))
+
+	s.prefix = "src"
+	assert.Equal(t, Modified, s.Lookup("/repo/sub/main.go"))
+
+	_, err = parse("x garbage\x00")
+	assert.NotNil(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := os.MkdirTemp("", "twf_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	_, err = run(dir, "init", "-q")
+	assert.Nil(t, err)
+	assert.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "sub", "file"), []byte("x"), 0644))
+
+	s, err := Load(filepath.Join(dir, "sub"))
+	assert.Nil(t, err)
+	assert.Equal(t, Untracked, s.Lookup(filepath.Join(dir, "sub", "file")))
+	assert.Equal(t, Dirty, s.Lookup(dir))
+
+	_, err = Load(os.TempDir())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,185 @@
+package highlight
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+const resetGraphics = "\x1b[m"
+
+type TokenKind int
+
+const (
+	Plain TokenKind = iota
+	Keyword
+	Builtin
+	String
+	Number
+	Comment
+	Heading
+)
+
+var tokenKindStrs = []string{"plain", "keyword", "builtin", "string", "number", "comment", "heading"}
+
+func (k TokenKind) String() string {
+	return tokenKindStrs[k]
+}
+
+// TokenKinds lists the kinds of tokens which can be styled.
+func TokenKinds() []TokenKind {
+	return []TokenKind{Keyword, Builtin, String, Number, Comment, Heading}
+}
+
+type rule struct {
+	kind TokenKind
+	re   *regexp.Regexp
+	// Only match at the start of a line.
+	lineStart bool
+}
+
+// Language describes how to split source code into tokens. Rules are tried
+// in order at every position, and words which don't match any rule are
+// looked up in the keywords and builtins.
+type Language struct {
+	Name     string
+	rules    []rule
+	keywords map[string]bool
+	builtins map[string]bool
+}
+
+var wordRegex = regexp.MustCompile(`^[\p{L}\p{N}_]+`)
+
+func newRule(kind TokenKind, expr string) rule {
+	return rule{kind: kind, re: regexp.MustCompile("^(?:" + expr + ")")}
+}
+
+func newLineStartRule(kind TokenKind, expr string) rule {
+	r := newRule(kind, expr)
+	r.lineStart = true
+	return r
+}
+
+func wordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+// Detect returns the language of a file based on its name or, failing that,
+// on the shebang in its first line. It returns nil for unknown languages.
+func Detect(path string, head string) *Language {
+	if lang, ok := languagesByExtension[strings.ToLower(filepath.Ext(path))]; ok {
+		return lang
+	}
+	if !strings.HasPrefix(head, "#!") {
+		return nil
+	}
+	shebang := head
+	if i := strings.IndexByte(shebang, '\n'); i >= 0 {
+		shebang = shebang[:i]
+	}
+	fields := strings.Fields(strings.TrimPrefix(shebang, "#!"))
+	if len(fields) == 0 {
+		return nil
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	for prefix, lang := range languagesByInterpreter {
+		if strings.HasPrefix(interpreter, prefix) {
+			return lang
+		}
+	}
+	return nil
+}
+
+type token struct {
+	kind TokenKind
+	text string
+}
+
+func (l *Language) tokenize(src string) []token {
+	tokens := []token{}
+	for pos := 0; pos < len(src); {
+		rest := src[pos:]
+		atLineStart := pos == 0 || src[pos-1] == '\n'
+		matched := false
+		for _, r := range l.rules {
+			if r.lineStart && !atLineStart {
+				continue
+			}
+			if loc := r.re.FindStringIndex(rest); loc != nil && loc[1] > 0 {
+				tokens = append(tokens, token{r.kind, rest[:loc[1]]})
+				pos += loc[1]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		if loc := wordRegex.FindStringIndex(rest); loc != nil {
+			word := rest[:loc[1]]
+			kind := Plain
+			if l.keywords[word] {
+				kind = Keyword
+			} else if l.builtins[word] {
+				kind = Builtin
+			}
+			tokens = append(tokens, token{kind, word})
+			pos += loc[1]
+			continue
+		}
+		tokens = append(tokens, token{Plain, rest[:1]})
+		pos++
+	}
+	return tokens
+}
+
+// Highlight splits the source into lines containing graphics escape codes
+// for the styled tokens. Every line is self-contained, so that lines can be
+// rendered independently.
+func (l *Language) Highlight(src string, styles map[TokenKind]*term.Graphics) []string {
+	lines := []string{}
+	current := &strings.Builder{}
+	for _, tok := range l.tokenize(src) {
+		style := styles[tok.kind]
+		pieces := strings.Split(tok.text, "\n")
+		for i, piece := range pieces {
+			if i > 0 {
+				lines = append(lines, current.String())
+				current.Reset()
+			}
+			if piece == "" {
+				continue
+			}
+			if style != nil && tok.kind != Plain {
+				current.WriteString(style.ToEscapeCode())
+				current.WriteString(piece)
+				current.WriteString(resetGraphics)
+			} else {
+				current.WriteString(piece)
+			}
+		}
+	}
+	return append(lines, current.String())
+}
+
+// HighlightPrefix is like Highlight, but only highlights the lines within the
+// first n bytes of the source, since highlighting large sources is slow. The
+// remaining lines are left as is.
+func (l *Language) HighlightPrefix(src string, n int, styles map[TokenKind]*term.Graphics) []string {
+	if len(src) <= n {
+		return l.Highlight(src, styles)
+	}
+	end := strings.LastIndexByte(src[:n], '\n') + 1
+	lines := l.Highlight(src[:end], styles)
+	// The last line is the empty one after the final newline.
+	return append(lines[:len(lines)-1], strings.Split(src[end:], "\n")...)
+}
@@ -0,0 +1,99 @@
+package highlight
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+var testStyles = map[TokenKind]*term.Graphics{
+	Keyword: &term.Graphics{Bold: true},
+	Builtin: &term.Graphics{FgColor: term.Color3Bit{Value: 3}},
+	String:  &term.Graphics{FgColor: term.Color3Bit{Value: 2}},
+	Number:  &term.Graphics{FgColor: term.Color3Bit{Value: 6}},
+	Comment: &term.Graphics{Reverse: true},
+	Heading: &term.Graphics{FgColor: term.Color3Bit{Value: 4}},
+}
+
+func TestDetect(t *testing.T) {
+	assert.Equal(t, Go, Detect("/a/main.go", "package main"))
+	assert.Equal(t, YAML, Detect("config.YML", ""))
+	assert.Equal(t, Python, Detect("script", "#!/usr/bin/env python3\nprint(1)"))
+	assert.Equal(t, Shell, Detect("script", "#!/bin/bash\n"))
+	assert.Nil(t, Detect("script", "#!/usr/bin/perl\n"))
+	assert.Nil(t, Detect("README", "Hello"))
+}
+
+func TestHighlightGo(t *testing.T) {
+	lines := Go.Highlight("func f() int {\n\treturn 42 // answer\n}", testStyles)
+	assert.Equal(
+		t,
+		[]string{
+			"\x1b[1mfunc\x1b[m f() \x1b[33mint\x1b[m {",
+			"\t\x1b[1mreturn\x1b[m \x1b[36m42\x1b[m \x1b[7m// answer\x1b[m",
+			"}",
+		},
+		lines,
+	)
+}
+
+func TestHighlightMultilineToken(t *testing.T) {
+	lines := Python.Highlight("x = \"\"\"a\nb\"\"\"\n", testStyles)
+	assert.Equal(
+		t,
+		[]string{
+			"x = \x1b[32m\"\"\"a\x1b[m",
+			"\x1b[32mb\"\"\"\x1b[m",
+			"",
+		},
+		lines,
+	)
+}
+
+func TestHighlightPrefix(t *testing.T) {
+	src := "var a\nvar b\nvar c"
+	assert.Equal(t, Go.Highlight(src, testStyles), Go.HighlightPrefix(src, len(src), testStyles))
+	assert.Equal(
+		t,
+		[]string{"\x1b[1mvar\x1b[m a", "var b", "var c"},
+		Go.HighlightPrefix(src, 8, testStyles),
+	)
+	assert.Equal(t, []string{"var a", "var b", "var c"}, Go.HighlightPrefix(src, 3, testStyles))
+}
+
+func TestHighlightJSON(t *testing.T) {
+	lines := JSON.Highlight(`{"a": [1, "b", true]}`, testStyles)
+	assert.Equal(
+		t,
+		[]string{
+			"{\x1b[33m\"a\":\x1b[m [\x1b[36m1\x1b[m, \x1b[32m\"b\"\x1b[m, \x1b[1mtrue\x1b[m]}",
+		},
+		lines,
+	)
+}
+
+func TestHighlightYAML(t *testing.T) {
+	lines := YAML.Highlight("key:\n  - 'v' # c", testStyles)
+	assert.Equal(
+		t,
+		[]string{
+			"\x1b[33mkey:\x1b[m",
+			"  - \x1b[32m'v'\x1b[m \x1b[7m# c\x1b[m",
+		},
+		lines,
+	)
+}
+
+func TestHighlightMarkdown(t *testing.T) {
+	lines := Markdown.Highlight("# Title\nSome `code` here.\n", testStyles)
+	assert.Equal(
+		t,
+		[]string{
+			"\x1b[34m# Title\x1b[m",
+			"Some \x1b[32m`code`\x1b[m here.",
+			"",
+		},
+		lines,
+	)
+}
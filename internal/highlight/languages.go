@@ -0,0 +1,142 @@
+package highlight
+
+const (
+	doubleQuotedString = `"(?:[^"\\\n]|\\.)*"`
+	singleQuotedString = `'(?:[^'\\\n]|\\.)*'`
+	cNumber            = `0[xX][0-9a-fA-F_]+|\d[\d_]*(?:\.\d+)?(?:[eE][+-]?\d+)?`
+	cLineComment       = `//[^\n]*`
+	cBlockComment      = `(?s:/\*.*?\*/)`
+	hashComment        = `#[^\n]*`
+)
+
+var Go = &Language{
+	Name: "go",
+	rules: []rule{
+		newRule(Comment, cLineComment),
+		newRule(Comment, cBlockComment),
+		newRule(String, doubleQuotedString),
+		newRule(String, "`[^`]*`"),
+		newRule(String, singleQuotedString),
+		newRule(Number, cNumber),
+	},
+	keywords: wordSet(`break case chan const continue default defer else fallthrough for func go
+		goto if import interface map package range return select struct switch type var`),
+	builtins: wordSet(`bool byte complex64 complex128 error float32 float64 int int8 int16 int32
+		int64 rune string uint uint8 uint16 uint32 uint64 uintptr true false iota nil append cap
+		close complex copy delete imag len make new panic print println real recover`),
+}
+
+var Python = &Language{
+	Name: "python",
+	rules: []rule{
+		newRule(Comment, hashComment),
+		newRule(String, `[rRbBuUfF]{0,2}(?s:""".*?"""|'''.*?''')`),
+		newRule(String, `[rRbBuUfF]{0,2}(?:`+doubleQuotedString+`|`+singleQuotedString+`)`),
+		newRule(Builtin, `@[\w.]+`),
+		newRule(Number, cNumber),
+	},
+	keywords: wordSet(`and as assert async await break class continue def del elif else except
+		finally for from global if import in is lambda nonlocal not or pass raise return try
+		while with yield`),
+	builtins: wordSet(`True False None self cls abs all any bool bytes dict enumerate filter float
+		int isinstance len list map max min object open print range repr set sorted str sum
+		super tuple type zip`),
+}
+
+var JavaScript = &Language{
+	Name: "javascript",
+	rules: []rule{
+		newRule(Comment, cLineComment),
+		newRule(Comment, cBlockComment),
+		newRule(String, doubleQuotedString),
+		newRule(String, singleQuotedString),
+		newRule(String, "(?s:`(?:[^`\\\\]|\\\\.)*`)"),
+		newRule(Number, cNumber),
+	},
+	keywords: wordSet(`async await break case catch class const continue debugger default delete
+		do else export extends finally for from function if import in instanceof let new of
+		return static super switch this throw try typeof var void while with yield`),
+	builtins: wordSet(`true false null undefined NaN Infinity Array Boolean Date Error JSON Map
+		Math Number Object Promise RegExp Set String Symbol console document window require
+		module exports`),
+}
+
+var Shell = &Language{
+	Name: "shell",
+	rules: []rule{
+		newRule(Comment, hashComment),
+		newRule(Builtin, `\$\{[^}\n]*\}|\$[\w@#?$!*-]`),
+		newRule(String, doubleQuotedString),
+		newRule(String, `'[^']*'`),
+		newRule(Number, `\d+`),
+	},
+	keywords: wordSet(`if then else elif fi for in do done case esac while until function select
+		return break continue local export readonly declare unset shift source exit`),
+	builtins: wordSet(`echo printf cd pwd read test eval exec set trap true false alias
+		command type wait kill`),
+}
+
+var JSON = &Language{
+	Name: "json",
+	rules: []rule{
+		newRule(Builtin, doubleQuotedString+`[ \t]*:`),
+		newRule(String, doubleQuotedString),
+		newRule(Number, `-?`+cNumber),
+	},
+	keywords: wordSet(`true false null`),
+}
+
+var YAML = &Language{
+	Name: "yaml",
+	rules: []rule{
+		newRule(Comment, hashComment),
+		newLineStartRule(Keyword, `---|\.\.\.`),
+		newRule(Builtin, `(?:`+doubleQuotedString+`|`+singleQuotedString+`|[\w.\-/]+)[ \t]*:(?:[ \t]|(?m:$))`),
+		newRule(String, doubleQuotedString),
+		newRule(String, singleQuotedString),
+		newRule(Builtin, `[&*][\w\-]+`),
+		newRule(Number, `-?`+cNumber),
+	},
+	keywords: wordSet(`true false null yes no on off True False Null`),
+}
+
+var Markdown = &Language{
+	Name: "markdown",
+	rules: []rule{
+		newLineStartRule(Heading, `#{1,6}[ \t][^\n]*`),
+		newLineStartRule(String, "(?s:```.*?(?:\n```|$))"),
+		newLineStartRule(Comment, `>[^\n]*`),
+		newLineStartRule(Keyword, `[ \t]*(?:[-*+]|\d+\.)[ \t]`),
+		newRule(String, "`[^`\n]+`"),
+		newRule(Keyword, `\*\*[^*\n]+\*\*|__[^_\n]+__`),
+		newRule(Builtin, `\[[^\]\n]*\]\([^)\n]*\)`),
+	},
+}
+
+var languagesByExtension = map[string]*Language{
+	".go":       Go,
+	".py":       Python,
+	".pyw":      Python,
+	".js":       JavaScript,
+	".mjs":      JavaScript,
+	".cjs":      JavaScript,
+	".jsx":      JavaScript,
+	".ts":       JavaScript,
+	".tsx":      JavaScript,
+	".sh":       Shell,
+	".bash":     Shell,
+	".zsh":      Shell,
+	".json":     JSON,
+	".yaml":     YAML,
+	".yml":      YAML,
+	".md":       Markdown,
+	".markdown": Markdown,
+}
+
+var languagesByInterpreter = map[string]*Language{
+	"python": Python,
+	"node":   JavaScript,
+	"bash":   Shell,
+	"zsh":    Shell,
+	"sh":     Shell,
+}
@@ -0,0 +1,93 @@
+package state
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/wvanlint/twf/internal/filetree"
+)
+
+// Interval at which paths which have been read are added to the tree.
+const pathBatchInterval = 50 * time.Millisecond
+
+// InsertPath adds a path to a tree created with filetree.NewPathTree. New
+// directories are expanded as they would be by AutoExpand.
+func (s *State) InsertPath(path string, maxDepth int, ignore *regexp.Regexp) error {
+	created, err := s.Root.Insert(path)
+	if err != nil {
+		return err
+	}
+	for _, node := range created {
+		if !node.IsDir() {
+			continue
+		}
+		expand, err := s.shouldExpand(node, node.Depth(), maxDepth, ignore)
+		if err != nil {
+			return err
+		}
+		if expand {
+			if err := node.Expand(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ReadPaths reads paths from r, one per line, and adds them to the tree
+// through post. Paths are added in batches while reading, so that the tree
+// can be shown before all paths have been read. Relative paths are relative
+// to the working directory, and paths outside of the tree are skipped.
+func (s *State) ReadPaths(r io.Reader, post filetree.Post, maxDepth int, ignore *regexp.Regexp) error {
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lines <- strings.TrimSuffix(scanner.Text(), "\r")
+		}
+		errs <- scanner.Err()
+	}()
+
+	batch := []string{}
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		paths := batch
+		batch = []string{}
+		post(func() error {
+			for _, path := range paths {
+				// Paths which don't exist or are outside of the tree are skipped.
+				s.InsertPath(path, maxDepth, ignore)
+			}
+			return nil
+		})
+	}
+	ticker := time.NewTicker(pathBatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				flush()
+				return <-errs
+			}
+			if line == "" {
+				continue
+			}
+			abs, err := filepath.Abs(line)
+			if err != nil {
+				continue
+			}
+			batch = append(batch, abs)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -3,22 +3,49 @@ package state
 import (
 	"path/filepath"
 	"regexp"
+	"strconv"
 
 	"github.com/wvanlint/twf/internal/filetree"
+	"github.com/wvanlint/twf/internal/git"
 )
 
 type State struct {
 	Root      *filetree.FileTree
 	Cursor    *filetree.FileTree
 	Selection []*filetree.FileTree
+	Loader    *filetree.Loader
+	// Runs functions on the event loop, nil until the terminal is opened.
+	Post filetree.Post
+	// Node last located with a line number, and that 1-based line number.
+	Located *filetree.FileTree
+	Line    int
+	// Whether paths are still being read by ReadPaths.
+	ReadingPaths bool
+	// Git status of the tree, or nil if unknown.
+	Git       *git.Status
+	DiskUsage *filetree.DiskUsage
 }
 
+var lineSuffixRegex = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?:?$`)
+
+// LocatePath moves the cursor to the path and expands its ancestors. The path
+// may be followed by a line number, as in path:42 or path:42:7, to show that
+// line in the preview.
 func (s *State) LocatePath(path string) error {
+	line := 0
 	node, err := s.Root.FindPath(path)
+	if _, ok := err.(filetree.PathNotFound); ok {
+		if m := lineSuffixRegex.FindStringSubmatch(path); m != nil {
+			line, _ = strconv.Atoi(m[2])
+			node, err = s.Root.FindPath(m[1])
+		}
+	}
 	if err != nil {
 		return err
 	}
 	s.Cursor = node
+	s.Located = node
+	s.Line = line
 	for node.Parent() != nil {
 		node = node.Parent()
 		err = node.Expand()
@@ -29,28 +56,58 @@ func (s *State) LocatePath(path string) error {
 	return nil
 }
 
-func (s *State) AutoExpand(maxDepth int, ignore *regexp.Regexp) error {
-	return s.Root.Traverse(true, nil, func(tree *filetree.FileTree, depth int) error {
-		if maxDepth >= 0 && depth >= maxDepth {
-			return nil
-		}
-		if tree == s.Root {
-			return tree.Expand()
-		}
-		parent := tree.Parent()
-		if parent != nil && !parent.Expanded() {
-			return nil
-		}
-
-		if ignore != nil {
-			rel, err := filepath.Rel(s.Root.AbsPath, tree.AbsPath)
+// RefreshGit loads the git status of the tree in the background and stores it
+// through post. Trees outside of git working trees have no status.
+func (s *State) RefreshGit(post filetree.Post) {
+	dir := s.Root.AbsPath
+	go func() {
+		status, err := git.Load(dir)
+		post(func() error {
 			if err != nil {
-				return err
-			}
-			if ignore.MatchString(rel) {
-				return nil
+				s.Git = nil
+			} else {
+				s.Git = status
 			}
+			return nil
+		})
+	}()
+}
+
+func (s *State) AutoExpand(maxDepth int, ignore *regexp.Regexp) error {
+	return s.Root.Traverse(true, nil, func(tree *filetree.FileTree, depth int) error {
+		expand, err := s.shouldExpand(tree, depth, maxDepth, ignore)
+		if err != nil || !expand {
+			return err
 		}
 		return tree.Expand()
 	})
 }
+
+// shouldExpand returns whether a node should be expanded automatically.
+func (s *State) shouldExpand(tree *filetree.FileTree, depth int, maxDepth int, ignore *regexp.Regexp) (bool, error) {
+	if maxDepth >= 0 && depth >= maxDepth {
+		return false, nil
+	}
+	if tree == s.Root {
+		return true, nil
+	}
+	parent := tree.Parent()
+	if parent != nil && !parent.Expanded() {
+		return false, nil
+	}
+	if tree.IsArchive() {
+		// Archives are only read when expanded explicitly.
+		return false, nil
+	}
+
+	if ignore != nil {
+		rel, err := filepath.Rel(s.Root.AbsPath, tree.AbsPath)
+		if err != nil {
+			return false, err
+		}
+		if ignore.MatchString(rel) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
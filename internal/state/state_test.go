@@ -2,6 +2,7 @@ package state
 
 import (
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -25,6 +26,22 @@ func TestAutoExpand(t *testing.T) {
 	}
 }
 
+func TestAutoExpandArchives(t *testing.T) {
+	fsys := filetree.NewMemFS()
+	fsys.AddFile("dir/notes.zip", "notes")
+	fsys.AddFile("x.tar.gz", "")
+	tree, err := filetree.InitFileTreeFS(fsys, ".")
+	assert.Nil(t, err)
+	state := &State{Root: tree}
+	assert.Nil(t, state.AutoExpand(-1, nil))
+	for _, name := range []string{"dir/notes.zip", "x.tar.gz"} {
+		node, err := tree.FindPath(name)
+		assert.Nil(t, err)
+		assert.True(t, node.IsArchive())
+		assert.False(t, node.Expanded())
+	}
+}
+
 func TestAutoExpandRegex(t *testing.T) {
 	re := regexp.MustCompile("dir1")
 	for curDepth := -1; curDepth < 3; curDepth++ {
@@ -59,3 +76,49 @@ func TestAutoExpandRegex(t *testing.T) {
 		})
 	}
 }
+
+func TestLocatePathWithLine(t *testing.T) {
+	tree, err := filetree.InitFileTree("../filetree/testdata")
+	assert.Nil(t, err)
+	state := &State{Root: tree}
+
+	assert.Nil(t, state.LocatePath("dir1/b:42"))
+	assert.Equal(t, "b", state.Cursor.Name())
+	assert.True(t, state.Cursor.Parent().Expanded())
+	assert.Equal(t, state.Cursor, state.Located)
+	assert.Equal(t, 42, state.Line)
+
+	assert.Nil(t, state.LocatePath("dir2/c:7:13:"))
+	assert.Equal(t, "c", state.Cursor.Name())
+	assert.Equal(t, 7, state.Line)
+
+	assert.Nil(t, state.LocatePath("a"))
+	assert.Equal(t, "a", state.Cursor.Name())
+	assert.Equal(t, 0, state.Line)
+
+	assert.NotNil(t, state.LocatePath("a:x"))
+	assert.NotNil(t, state.LocatePath("d:3"))
+}
+
+func TestReadPaths(t *testing.T) {
+	tree, err := filetree.NewPathTree("../filetree/testdata")
+	assert.Nil(t, err)
+	state := &State{Root: tree}
+	assert.Nil(t, state.AutoExpand(1, nil))
+
+	input := "../filetree/testdata/dir1/b\n\n../filetree/testdata/a\n../state.go\n"
+	post := func(f func() error) { assert.Nil(t, f()) }
+	assert.Nil(t, state.ReadPaths(strings.NewReader(input), post, 1, nil))
+
+	names := []string{}
+	state.Root.Traverse(true, nil, func(node *filetree.FileTree, _ int) error {
+		names = append(names, node.Name())
+		return nil
+	})
+	assert.Equal(t, []string{"testdata", "dir1", "a"}, names)
+
+	assert.Nil(t, state.ReadPaths(strings.NewReader("../filetree/testdata/dir2/c\n"), post, -1, nil))
+	dir2, err := state.Root.FindPath("dir2")
+	assert.Nil(t, err)
+	assert.True(t, dir2.Expanded())
+}
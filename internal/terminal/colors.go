@@ -0,0 +1,262 @@
+package terminal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// ColorDepth is the range of colors supported by a terminal. Colors which
+// aren't supported are replaced by the nearest supported ones when rendering.
+type ColorDepth int
+
+const (
+	// Colors24Bit is the default, with which colors are rendered as is.
+	Colors24Bit ColorDepth = iota
+	Colors8Bit
+	// Colors3Bit are the 8 colors and their bright variants.
+	Colors3Bit
+	NoColors
+)
+
+// DetectColorDepth detects the color depth of the terminal from environment
+// variables and terminfo, with getenv such as os.Getenv. Colors are only
+// downgraded if a lower depth is detected, so terminals without a terminfo
+// entry are assumed to support 24-bit colors.
+func DetectColorDepth(getenv func(string) string) ColorDepth {
+	if getenv("NO_COLOR") != "" {
+		return NoColors
+	}
+	colorTerm := getenv("COLORTERM")
+	if colorTerm == "truecolor" || colorTerm == "24bit" {
+		return Colors24Bit
+	}
+	term := getenv("TERM")
+	switch {
+	case term == "dumb":
+		return NoColors
+	case strings.HasSuffix(term, "-direct") || strings.Contains(term, "truecolor"):
+		return Colors24Bit
+	case strings.Contains(term, "256color"):
+		return Colors8Bit
+	}
+	if colors, err := terminfoMaxColors(term, getenv); err == nil {
+		switch {
+		case colors >= 1<<24:
+			return Colors24Bit
+		case colors >= 256:
+			return Colors8Bit
+		case colors >= 8:
+			return Colors3Bit
+		default:
+			return NoColors
+		}
+	}
+	return Colors24Bit
+}
+
+// terminfoDirs returns the directories in which terminfo descriptions are
+// searched, in the order used by ncurses.
+func terminfoDirs(getenv func(string) string) []string {
+	dirs := []string{}
+	if dir := getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home := getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	defaultDirs := []string{"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo"}
+	if list := getenv("TERMINFO_DIRS"); list != "" {
+		for _, dir := range strings.Split(list, ":") {
+			if dir == "" {
+				dirs = append(dirs, defaultDirs...)
+			} else {
+				dirs = append(dirs, dir)
+			}
+		}
+	} else {
+		dirs = append(dirs, defaultDirs...)
+	}
+	return dirs
+}
+
+// Index of max_colors among the numeric capabilities of terminfo.
+const maxColorsIndex = 13
+
+// terminfoMaxColors reads the number of colors of a terminal from its compiled
+// terminfo description. It is 0 if the terminal has no colors.
+func terminfoMaxColors(term string, getenv func(string) string) (int, error) {
+	if term == "" || strings.Contains(term, "/") {
+		return 0, fmt.Errorf("Invalid terminal name: %q", term)
+	}
+	for _, dir := range terminfoDirs(getenv) {
+		for _, subdir := range []string{term[:1], fmt.Sprintf("%x", term[0])} {
+			data, err := ioutil.ReadFile(filepath.Join(dir, subdir, term))
+			if err == nil {
+				return parseTerminfoMaxColors(data)
+			}
+		}
+	}
+	return 0, fmt.Errorf("No terminfo description of %s", term)
+}
+
+// parseTerminfoMaxColors parses max_colors from a compiled terminfo
+// description, in the legacy format or in the format with 32-bit numbers.
+func parseTerminfoMaxColors(data []byte) (int, error) {
+	if len(data) < 12 {
+		return 0, fmt.Errorf("Terminfo header too short")
+	}
+	header := make([]int, 6)
+	for i := range header {
+		header[i] = int(int16(binary.LittleEndian.Uint16(data[2*i:])))
+	}
+	numberSize := 2
+	switch header[0] {
+	case 0432:
+	case 01036:
+		numberSize = 4
+	default:
+		return 0, fmt.Errorf("Unknown terminfo format: %o", header[0])
+	}
+	nameSize, boolCount, numberCount := header[1], header[2], header[3]
+	if nameSize < 0 || boolCount < 0 || numberCount <= maxColorsIndex {
+		return 0, nil
+	}
+	offset := 12 + nameSize + boolCount
+	if offset%2 != 0 {
+		offset++
+	}
+	offset += maxColorsIndex * numberSize
+	if offset+numberSize > len(data) {
+		return 0, fmt.Errorf("Terminfo numbers too short")
+	}
+	colors := 0
+	if numberSize == 2 {
+		colors = int(int16(binary.LittleEndian.Uint16(data[offset:])))
+	} else {
+		colors = int(int32(binary.LittleEndian.Uint32(data[offset:])))
+	}
+	if colors < 0 {
+		// Absent.
+		return 0, nil
+	}
+	return colors, nil
+}
+
+// ansiPalette are the usual RGB values of the 3-bit colors, followed by
+// their bright variants, as in xterm.
+var ansiPalette = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// Levels of the components of the 6x6x6 color cube of 8-bit colors.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+func colorDistance(a [3]int, b [3]int) int {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dr*dr + dg*dg + db*db
+}
+
+// rgb8Bit returns the RGB value of an 8-bit color.
+func rgb8Bit(value int) [3]int {
+	switch {
+	case value < 16:
+		return ansiPalette[value]
+	case value < 232:
+		value -= 16
+		return [3]int{cubeLevels[value/36], cubeLevels[value/6%6], cubeLevels[value%6]}
+	default:
+		gray := 8 + (value-232)*10
+		return [3]int{gray, gray, gray}
+	}
+}
+
+// nearest8Bit returns the 8-bit color of the color cube or the grayscale
+// ramp nearest to an RGB value.
+func nearest8Bit(rgb [3]int) Color8Bit {
+	cube := 16
+	for i, component := range rgb {
+		level := 0
+		for j := range cubeLevels {
+			if abs(cubeLevels[j]-component) < abs(cubeLevels[level]-component) {
+				level = j
+			}
+		}
+		cube += level * []int{36, 6, 1}[i]
+	}
+	average := (rgb[0] + rgb[1] + rgb[2]) / 3
+	gray := 232 + clamp((average-8+5)/10, 0, 23)
+	if colorDistance(rgb8Bit(gray), rgb) < colorDistance(rgb8Bit(cube), rgb) {
+		return Color8Bit{Value: gray}
+	}
+	return Color8Bit{Value: cube}
+}
+
+// nearest3Bit returns the 3-bit color nearest to an RGB value.
+func nearest3Bit(rgb [3]int) Color3Bit {
+	nearest := 0
+	for i := range ansiPalette {
+		if colorDistance(ansiPalette[i], rgb) < colorDistance(ansiPalette[nearest], rgb) {
+			nearest = i
+		}
+	}
+	return Color3Bit{Value: nearest % 8, Bright: nearest >= 8}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// downgrade returns the nearest color supported at a color depth, or nil if
+// colors aren't supported.
+func downgrade(c Color, depth ColorDepth) Color {
+	if c == nil || depth == NoColors {
+		return nil
+	}
+	switch color := c.(type) {
+	case Color24Bit:
+		rgb := [3]int{color.R, color.G, color.B}
+		if depth == Colors8Bit {
+			return nearest8Bit(rgb)
+		} else if depth == Colors3Bit {
+			return nearest3Bit(rgb)
+		}
+	case Color8Bit:
+		if depth == Colors3Bit {
+			if color.Value < 16 {
+				return Color3Bit{Value: color.Value % 8, Bright: color.Value >= 8}
+			}
+			return nearest3Bit(rgb8Bit(color.Value))
+		}
+	}
+	return c
+}
+
+// downgradeColors replaces the colors of the cells by those supported at a
+// color depth. Underline colors are only kept with 256 colors or more, which
+// terminals supporting them do.
+func (s *screen) downgradeColors(depth ColorDepth) {
+	if depth == Colors24Bit {
+		return
+	}
+	for _, cells := range s.cells {
+		for i := range cells {
+			st := &cells[i].style
+			st.fg = downgrade(st.fg, depth)
+			st.bg = downgrade(st.bg, depth)
+			if depth == Colors3Bit {
+				st.ul = nil
+			} else {
+				st.ul = downgrade(st.ul, depth)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package terminal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// terminfoEntry returns a compiled terminfo description with max_colors.
+func terminfoEntry(magic int, maxColors int) []byte {
+	numberSize := 2
+	if magic == 01036 {
+		numberSize = 4
+	}
+	buffer := new(bytes.Buffer)
+	name := []byte("test\x00")
+	bools := []byte{1, 0}
+	for _, value := range []int{magic, len(name), len(bools), 14, 0, 0} {
+		binary.Write(buffer, binary.LittleEndian, int16(value))
+	}
+	buffer.Write(name)
+	buffer.Write(bools)
+	buffer.WriteByte(0) // Padding to an even offset.
+	for i := 0; i < 14; i++ {
+		value := -1
+		if i == maxColorsIndex {
+			value = maxColors
+		}
+		if numberSize == 2 {
+			binary.Write(buffer, binary.LittleEndian, int16(value))
+		} else {
+			binary.Write(buffer, binary.LittleEndian, int32(value))
+		}
+	}
+	return buffer.Bytes()
+}
+
+func TestDetectColorDepth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "twf_")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "t"), 0755))
+	entries := map[string][]byte{
+		"test-8":      terminfoEntry(0432, 8),
+		"test-256":    terminfoEntry(0432, 256),
+		"test-direct": terminfoEntry(01036, 1<<24),
+		"test-mono":   terminfoEntry(0432, -1),
+	}
+	for name, data := range entries {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "t", name), data, 0644))
+	}
+
+	cases := []struct {
+		env   map[string]string
+		depth ColorDepth
+	}{
+		{map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, Colors24Bit},
+		{map[string]string{"TERM": "xterm-256color", "NO_COLOR": "1"}, NoColors},
+		{map[string]string{"TERM": "xterm-256color", "NO_COLOR": ""}, Colors8Bit},
+		{map[string]string{"TERM": "xterm-direct"}, Colors24Bit},
+		{map[string]string{"TERM": "dumb"}, NoColors},
+		{map[string]string{"TERM": "test-8"}, Colors3Bit},
+		{map[string]string{"TERM": "test-256"}, Colors8Bit},
+		{map[string]string{"TERM": "test-mono"}, NoColors},
+		{map[string]string{"TERM": "test-unknown"}, Colors24Bit},
+		{map[string]string{}, Colors24Bit},
+	}
+	for _, c := range cases {
+		c.env["TERMINFO"] = dir
+		c.env["TERMINFO_DIRS"] = dir
+		getenv := func(key string) string { return c.env[key] }
+		assert.Equal(t, c.depth, DetectColorDepth(getenv), c.env["TERM"])
+	}
+	colors, err := terminfoMaxColors("test-direct", func(key string) string {
+		return map[string]string{"TERMINFO": dir}[key]
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1<<24, colors)
+}
+
+func TestDowngradeColors(t *testing.T) {
+	orange := Color24Bit{R: 255, G: 135, B: 0}
+	gray := Color24Bit{R: 100, G: 100, B: 102}
+	assert.Equal(t, Color8Bit{Value: 208}, downgrade(orange, Colors8Bit))
+	assert.Equal(t, Color8Bit{Value: 241}, downgrade(gray, Colors8Bit))
+	assert.Equal(t, orange, downgrade(orange, Colors24Bit))
+	assert.Equal(t, Color3Bit{Value: 3}, downgrade(orange, Colors3Bit))
+	assert.Equal(t, Color3Bit{Value: 0, Bright: true}, downgrade(gray, Colors3Bit))
+	assert.Equal(t, Color3Bit{Value: 1, Bright: true}, downgrade(Color8Bit{Value: 9}, Colors3Bit))
+	assert.Equal(t, Color3Bit{Value: 4, Bright: true}, downgrade(Color8Bit{Value: 63}, Colors3Bit))
+	assert.Equal(t, Color3Bit{Value: 2}, downgrade(Color3Bit{Value: 2}, Colors8Bit))
+	assert.Nil(t, downgrade(Color3Bit{Value: 2}, NoColors))
+
+	s := newScreen(1, 3)
+	s.setText(1, 1, 3, "\x1b[1;38;2;255;135;0;48;5;9;4;58;5;1mab")
+	s.downgradeColors(Colors3Bit)
+	assert.Equal(t, style{
+		fg:        Color3Bit{Value: 3},
+		bg:        Color3Bit{Value: 1, Bright: true},
+		attrs:     AttrBold,
+		underline: SingleUnderline,
+	}, s.cells[0][0].style)
+	s.downgradeColors(NoColors)
+	assert.Equal(t, style{attrs: AttrBold, underline: SingleUnderline}, s.cells[0][2].style)
+}
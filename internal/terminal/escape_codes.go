@@ -25,6 +25,15 @@ const (
 	enableWrap    = csi + "?7h"
 	disableWrap   = csi + "?7l"
 
+	// Pasted text is sent between markers, instead of as typed keys.
+	enableBracketedPaste  = csi + "?2004h"
+	disableBracketedPaste = csi + "?2004l"
+
+	// Progressive enhancement of the kitty keyboard protocol, disambiguating
+	// keys such as ctrl-i and Tab.
+	enableKittyKeyboard  = csi + ">1u"
+	disableKittyKeyboard = csi + "<u"
+
 	deviceStatusReport = csi + "6n"
 	saveCursor         = csi + "s"
 	restoreCursor      = csi + "u"
@@ -35,12 +44,19 @@ const (
 
 	resetGraphics = csi + "m"
 
-	bold      = "1"
-	faint     = "2"
-	reverse   = "7"
-	nobold    = "21"
-	nofaint   = "22"
-	noreverse = "27"
+	bold            = "1"
+	faint           = "2"
+	italic          = "3"
+	underline       = "4"
+	blink           = "5"
+	reverse         = "7"
+	strikethrough   = "9"
+	nobold          = "22" // Also ends faint text.
+	noitalic        = "23"
+	nounderline     = "24"
+	noblink         = "25"
+	noreverse       = "27"
+	nostrikethrough = "29"
 )
 
 func cursorUp(args ...int) string {
@@ -94,8 +110,13 @@ func cursorPosition(row int, column int) string {
 type Color interface {
 	FgCode() string
 	BgCode() string
+	UnderlineCode() string
 }
 
+// DefaultColor is the default color of the terminal, which can be set
+// explicitly to override the colors of other graphics.
+type DefaultColor struct{}
+
 type Color3Bit struct {
 	Value  int
 	Bright bool
@@ -109,6 +130,18 @@ type Color24Bit struct {
 	R, G, B int
 }
 
+func (c DefaultColor) FgCode() string {
+	return "39"
+}
+
+func (c DefaultColor) BgCode() string {
+	return "49"
+}
+
+func (c DefaultColor) UnderlineCode() string {
+	return "59"
+}
+
 func (c Color3Bit) FgCode() string {
 	if c.Bright {
 		return fmt.Sprint("9", c.Value)
@@ -125,6 +158,16 @@ func (c Color3Bit) BgCode() string {
 	}
 }
 
+// UnderlineCode uses the first 16 colors of the 256-color palette, since
+// underline colors can't be set from 3-bit colors.
+func (c Color3Bit) UnderlineCode() string {
+	if c.Bright {
+		return fmt.Sprint("58;5;", c.Value+8)
+	} else {
+		return fmt.Sprint("58;5;", c.Value)
+	}
+}
+
 func (c Color8Bit) FgCode() string {
 	return fmt.Sprint("38;5;", c.Value)
 }
@@ -133,6 +176,10 @@ func (c Color8Bit) BgCode() string {
 	return fmt.Sprint("48;5;", c.Value)
 }
 
+func (c Color8Bit) UnderlineCode() string {
+	return fmt.Sprint("58;5;", c.Value)
+}
+
 func (c Color24Bit) FgCode() string {
 	return fmt.Sprint("38;2;", c.R, ";", c.G, ";", c.B)
 }
@@ -141,32 +188,159 @@ func (c Color24Bit) BgCode() string {
 	return fmt.Sprint("48;2;", c.R, ";", c.G, ";", c.B)
 }
 
+func (c Color24Bit) UnderlineCode() string {
+	return fmt.Sprint("58;2;", c.R, ";", c.G, ";", c.B)
+}
+
+// UnderlineStyle is the style of underlined text, with the value of the
+// parameter following 4 in an SGR escape code, as in "4:3" for curly
+// underlines. Terminals not supporting a style show a single underline.
+type UnderlineStyle int
+
+const (
+	NoUnderline UnderlineStyle = iota
+	SingleUnderline
+	DoubleUnderline
+	CurlyUnderline
+	DottedUnderline
+	DashedUnderline
+)
+
+func (u UnderlineStyle) code() string {
+	if u == SingleUnderline {
+		return underline
+	}
+	return fmt.Sprint(underline, ":", int(u))
+}
+
+// Attributes is a set of text attributes of Graphics.
+type Attributes int
+
+const (
+	AttrBold Attributes = 1 << iota
+	AttrDim
+	AttrItalic
+	AttrUnderline
+	AttrBlink
+	AttrReverse
+	AttrStrikethrough
+)
+
+// sgrAttributes are the SGR parameters which turn attributes on and off.
+// Underlines have styles and are handled separately.
+var sgrAttributes = []struct {
+	attr    Attributes
+	on, off int
+}{
+	{AttrBold, 1, 22},
+	{AttrDim, 2, 22},
+	{AttrItalic, 3, 23},
+	{AttrBlink, 5, 25},
+	{AttrReverse, 7, 27},
+	{AttrStrikethrough, 9, 29},
+}
+
 type Graphics struct {
-	FgColor Color
-	BgColor Color
+	FgColor        Color
+	BgColor        Color
+	UnderlineColor Color
+
+	Bold          bool
+	Dim           bool
+	Italic        bool
+	Underline     UnderlineStyle
+	Blink         bool
+	Reverse       bool
+	Strikethrough bool
 
-	Bold    bool
-	Reverse bool
+	// Off are the attributes which are turned off, even if they are set by
+	// the surrounding text or by merged graphics.
+	Off Attributes
+}
+
+// Attributes returns the attributes which are turned on.
+func (g *Graphics) Attributes() Attributes {
+	attrs := Attributes(0)
+	if g.Bold {
+		attrs |= AttrBold
+	}
+	if g.Dim {
+		attrs |= AttrDim
+	}
+	if g.Italic {
+		attrs |= AttrItalic
+	}
+	if g.Underline != NoUnderline {
+		attrs |= AttrUnderline
+	}
+	if g.Blink {
+		attrs |= AttrBlink
+	}
+	if g.Reverse {
+		attrs |= AttrReverse
+	}
+	if g.Strikethrough {
+		attrs |= AttrStrikethrough
+	}
+	return attrs
 }
 
 func (g *Graphics) ToEscapeCode() string {
 	codes := []string{}
+	// Attributes are turned off first, since ending bold text also ends dim
+	// text.
+	offCodes := []struct {
+		attr Attributes
+		code string
+	}{
+		{AttrBold | AttrDim, nobold},
+		{AttrItalic, noitalic},
+		{AttrUnderline, nounderline},
+		{AttrBlink, noblink},
+		{AttrReverse, noreverse},
+		{AttrStrikethrough, nostrikethrough},
+	}
+	for _, off := range offCodes {
+		if g.Off&off.attr != 0 {
+			codes = append(codes, off.code)
+		}
+	}
 	if g.Bold {
 		codes = append(codes, bold)
 	}
+	if g.Dim {
+		codes = append(codes, faint)
+	}
+	if g.Italic {
+		codes = append(codes, italic)
+	}
+	if g.Underline != NoUnderline {
+		codes = append(codes, g.Underline.code())
+	}
+	if g.Blink {
+		codes = append(codes, blink)
+	}
 	if g.Reverse {
 		codes = append(codes, reverse)
 	}
+	if g.Strikethrough {
+		codes = append(codes, strikethrough)
+	}
 	if g.FgColor != nil {
 		codes = append(codes, g.FgColor.FgCode())
 	}
 	if g.BgColor != nil {
 		codes = append(codes, g.BgColor.BgCode())
 	}
+	if g.UnderlineColor != nil {
+		codes = append(codes, g.UnderlineColor.UnderlineCode())
+	}
 
 	return csi + strings.Join(codes, ";") + "m"
 }
 
+// Merge combines the graphics with those of other. Colors which are set take
+// precedence over those of other, while other can turn attributes on or off.
 func (g *Graphics) Merge(other *Graphics) {
 	if g.FgColor == nil {
 		g.FgColor = other.FgColor
@@ -174,8 +348,43 @@ func (g *Graphics) Merge(other *Graphics) {
 	if g.BgColor == nil {
 		g.BgColor = other.BgColor
 	}
-	g.Bold = g.Bold || other.Bold
-	g.Reverse = g.Reverse || other.Reverse
+	if g.UnderlineColor == nil {
+		g.UnderlineColor = other.UnderlineColor
+	}
+	on := other.Attributes()
+	attrs := g.Attributes()&^other.Off | on
+	g.Off = g.Off&^on | other.Off
+	g.Bold = attrs&AttrBold != 0
+	g.Dim = attrs&AttrDim != 0
+	g.Italic = attrs&AttrItalic != 0
+	if other.Underline != NoUnderline {
+		g.Underline = other.Underline
+	} else if attrs&AttrUnderline == 0 {
+		g.Underline = NoUnderline
+	}
+	g.Blink = attrs&AttrBlink != 0
+	g.Reverse = attrs&AttrReverse != 0
+	g.Strikethrough = attrs&AttrStrikethrough != 0
+}
+
+// ParseSGR returns the graphics set by the parameters of an SGR escape code,
+// such as "01;34" in LS_COLORS. Parameters which aren't understood are
+// ignored.
+func ParseSGR(params string) *Graphics {
+	s := style{}
+	s.apply(params)
+	return &Graphics{
+		FgColor:        s.fg,
+		BgColor:        s.bg,
+		UnderlineColor: s.ul,
+		Bold:           s.attrs&AttrBold != 0,
+		Dim:            s.attrs&AttrDim != 0,
+		Italic:         s.attrs&AttrItalic != 0,
+		Underline:      s.underline,
+		Blink:          s.attrs&AttrBlink != 0,
+		Reverse:        s.attrs&AttrReverse != 0,
+		Strikethrough:  s.attrs&AttrStrikethrough != 0,
+	}
 }
 
 func readReport(in io.Reader) (int, int, error) {
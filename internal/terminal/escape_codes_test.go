@@ -72,3 +72,66 @@ func TestGraphicsToEscapeCode(t *testing.T) {
 		}).ToEscapeCode(),
 	)
 }
+
+func TestExtendedGraphicsToEscapeCode(t *testing.T) {
+	assert.Equal(
+		t,
+		"\x1b[2;3;4:3;9;39;58;5;12m",
+		(&Graphics{
+			Dim:            true,
+			Italic:         true,
+			Underline:      CurlyUnderline,
+			Strikethrough:  true,
+			FgColor:        DefaultColor{},
+			UnderlineColor: Color3Bit{Value: 4, Bright: true},
+		}).ToEscapeCode(),
+	)
+	assert.Equal(
+		t,
+		"\x1b[22;27;1m",
+		(&Graphics{
+			Bold: true,
+			Off:  AttrDim | AttrReverse,
+		}).ToEscapeCode(),
+	)
+}
+
+func TestGraphicsMerge(t *testing.T) {
+	g := &Graphics{
+		FgColor: Color3Bit{Value: 1},
+		Bold:    true,
+		Reverse: true,
+	}
+	g.Merge(&Graphics{
+		FgColor:   Color3Bit{Value: 2},
+		BgColor:   DefaultColor{},
+		Italic:    true,
+		Underline: DoubleUnderline,
+		Off:       AttrBold,
+	})
+	assert.Equal(
+		t,
+		&Graphics{
+			FgColor:   Color3Bit{Value: 1},
+			BgColor:   DefaultColor{},
+			Italic:    true,
+			Underline: DoubleUnderline,
+			Reverse:   true,
+			Off:       AttrBold,
+		},
+		g,
+	)
+
+	g.Merge(&Graphics{Bold: true, Off: AttrUnderline | AttrReverse})
+	assert.Equal(
+		t,
+		&Graphics{
+			FgColor: Color3Bit{Value: 1},
+			BgColor: DefaultColor{},
+			Bold:    true,
+			Italic:  true,
+			Off:     AttrUnderline | AttrReverse,
+		},
+		g,
+	)
+}
@@ -1,11 +1,16 @@
 package terminal
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"time"
 	"unicode/utf8"
 
 	"go.uber.org/zap"
+	sys "golang.org/x/sys/unix"
 )
 
 const (
@@ -48,22 +53,29 @@ const (
 	Left
 	Right
 
-	ShiftUp
-	ShiftDown
-	ShiftLeft
-	ShiftRight
-
-	CtrlUp
-	CtrlDown
-	CtrlLeft
-	CtrlRight
-
 	Home
 	End
 	PgUp
 	PgDown
+	Insert
+	Delete
+
+	F1
+	F2
+	F3
+	F4
+	F5
+	F6
+	F7
+	F8
+	F9
+	F10
+	F11
+	F12
 
 	Rune
+	// Text pasted in the terminal at once.
+	Paste
 )
 
 const (
@@ -74,125 +86,366 @@ const (
 
 type EventSymbol int
 
+// Modifier is a bitmask of the modifier keys held during a key press, as
+// encoded in the parameters of xterm escape sequences.
+type Modifier int
+
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt
+	ModCtrl
+	ModMeta
+)
+
 type Event struct {
 	Symbol EventSymbol
 	Value  rune
+	Mod    Modifier
+	// Text of a Paste event.
+	Text string
 }
 
 func (e *Event) HashKey() string {
+	key := ""
 	if e.Symbol == Rune {
-		return string(e.Value)
+		key = string(e.Value)
 	} else {
-		return fmt.Sprint("#", e.Symbol)
+		key = fmt.Sprint("#", e.Symbol)
+	}
+	if e.Mod != 0 {
+		key = fmt.Sprint("@", int(e.Mod), ":", key)
 	}
+	return key
+}
+
+// Symbols of CSI sequences ending in a letter, such as "ESC [ A" or
+// "ESC [ 1 ; 5 A", and of SS3 sequences such as "ESC O A".
+var csiLetterSymbols = map[byte]EventSymbol{
+	'A': Up,
+	'B': Down,
+	'C': Right,
+	'D': Left,
+	'H': Home,
+	'F': End,
+	'P': F1,
+	'Q': F2,
+	'R': F3,
+	'S': F4,
 }
 
-func hasPrefix(s []byte, prefix []byte) bool {
-	if (s == nil) != (prefix == nil) {
-		return false
+// Symbols of CSI sequences ending in a tilde by their first parameter, such
+// as "ESC [ 5 ~" or "ESC [ 5 ; 3 ~".
+var csiTildeSymbols = map[int]EventSymbol{
+	1:  Home,
+	2:  Insert,
+	3:  Delete,
+	4:  End,
+	5:  PgUp,
+	6:  PgDown,
+	7:  Home,
+	8:  End,
+	11: F1,
+	12: F2,
+	13: F3,
+	14: F4,
+	15: F5,
+	17: F6,
+	18: F7,
+	19: F8,
+	20: F9,
+	21: F10,
+	23: F11,
+	24: F12,
+}
+
+// decodeEvent decodes the first key in the input. It returns the number of
+// bytes used, or 0 if the input ends within a key. A lone escape could be the
+// start of an escape sequence, so it is not decoded either. Sequences which
+// are not understood are skipped without returning an event.
+func decodeEvent(in []byte) (*Event, int) {
+	if len(in) == 0 {
+		return nil, 0
+	}
+	if in[0] != Escape {
+		return decodeKey(in)
+	}
+	if len(in) == 1 {
+		return nil, 0
+	}
+	if in[1] == Escape {
+		return &Event{Symbol: Escape}, 1
+	}
+	switch {
+	case bytes.HasPrefix(in, pasteStart):
+		return decodePaste(in)
+	case in[1] == '[':
+		return decodeCSI(in)
+	case in[1] == 'O':
+		if len(in) < 3 {
+			return nil, 0
+		}
+		if symbol, ok := csiLetterSymbols[in[2]]; ok {
+			return &Event{Symbol: symbol}, 3
+		}
+		return nil, 3
+	}
+	// Alt sends an escape before the key.
+	event, n := decodeKey(in[1:])
+	if n == 0 {
+		return nil, 0
 	}
+	if event != nil {
+		event.Mod |= ModAlt
+	}
+	return event, n + 1
+}
+
+// Markers around pasted text in bracketed paste mode.
+var (
+	pasteStart = []byte("\x1b[200~")
+	pasteEnd   = []byte("\x1b[201~")
+)
+
+// decodePaste decodes text pasted in bracketed paste mode. Line breaks are
+// sent as carriage returns, and are converted to newlines.
+func decodePaste(in []byte) (*Event, int) {
+	end := bytes.Index(in, pasteEnd)
+	if end < 0 {
+		return nil, 0
+	}
+	text := string(in[len(pasteStart):end])
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return &Event{Symbol: Paste, Text: text}, end + len(pasteEnd)
+}
+
+// decodeKey decodes a key which doesn't start with an escape.
+func decodeKey(in []byte) (*Event, int) {
+	switch {
+	case in[0] <= 31:
+		return &Event{Symbol: EventSymbol(in[0])}, 1
+	case in[0] == Del:
+		return &Event{Symbol: Del}, 1
+	case !utf8.FullRune(in):
+		return nil, 0
+	}
+	r, size := utf8.DecodeRune(in)
+	if r == utf8.RuneError {
+		return nil, size
+	}
+	return &Event{Symbol: Rune, Value: r}, size
+}
 
-	if len(s) < len(prefix) {
-		return false
+// decodeCSI decodes a control sequence: "ESC [", parameter bytes,
+// intermediate bytes and a final byte.
+func decodeCSI(in []byte) (*Event, int) {
+	i := 2
+	for i < len(in) && in[i] >= 0x30 && in[i] <= 0x3f {
+		i++
+	}
+	paramsEnd := i
+	for i < len(in) && in[i] >= 0x20 && in[i] <= 0x2f {
+		i++
+	}
+	if i == len(in) {
+		return nil, 0
+	}
+	if in[i] < 0x40 || in[i] > 0x7e {
+		// Malformed, skip the introducer.
+		return nil, 2
+	}
+	final, n := in[i], i+1
+	params := parseParams(string(in[2:paramsEnd]))
+	mod := Modifier(0)
+	if len(params) > 1 && params[1] > 1 {
+		// Lock keys in the kitty keyboard protocol are ignored.
+		mod = Modifier(params[1]-1) & (ModShift | ModAlt | ModCtrl | ModMeta)
+	}
+
+	switch final {
+	case '~':
+		if len(params) > 0 {
+			if symbol, ok := csiTildeSymbols[params[0]]; ok {
+				return &Event{Symbol: symbol, Mod: mod}, n
+			}
+		}
+	case 'Z':
+		return &Event{Symbol: Tab, Mod: ModShift}, n
+	case 'u':
+		if len(params) > 0 {
+			return decodeKittyKey(params[0], mod), n
+		}
+	default:
+		if symbol, ok := csiLetterSymbols[final]; ok {
+			return &Event{Symbol: symbol, Mod: mod}, n
+		}
 	}
+	return nil, n
+}
+
+// Symbols of keys in the kitty keyboard protocol which aren't reported as
+// their text.
+var kittySymbols = map[int]EventSymbol{
+	9:   Tab,
+	13:  Enter,
+	27:  Escape,
+	127: Del,
+}
+
+// Control characters by the key pressed with ctrl. The keys i, m and [ are
+// missing, since their control characters are Tab, Enter and Escape.
+var ctrlSymbols = map[rune]EventSymbol{
+	' ':  CtrlSpace,
+	'@':  CtrlSpace,
+	'\\': CtrlBackslash,
+	']':  CtrlRightBracket,
+	'^':  CtrlCaret,
+	'/':  CtrlSlash,
+	'_':  CtrlSlash,
+}
 
-	for i := range prefix {
-		if s[i] != prefix[i] {
-			return false
+func init() {
+	for r := 'a'; r <= 'z'; r++ {
+		if r != 'i' && r != 'm' {
+			ctrlSymbols[r] = EventSymbol(CtrlA + r - 'a')
 		}
 	}
+}
 
-	return true
+// decodeKittyKey decodes a key of the kitty keyboard protocol, "ESC [ code ;
+// modifiers u". Keys are reported as in other terminals where possible, so
+// that ctrl-a is CtrlA. Only ctrl-i, ctrl-m and ctrl-[ differ, and are runes
+// with ModCtrl instead of Tab, Enter and Escape.
+func decodeKittyKey(code int, mod Modifier) *Event {
+	if symbol, ok := kittySymbols[code]; ok {
+		return &Event{Symbol: symbol, Mod: mod}
+	}
+	r := rune(code)
+	// Keys without text, such as keypad and media keys, are in the private
+	// use area.
+	if r < ' ' || (r >= 0xe000 && r <= 0xf8ff) || !utf8.ValidRune(r) {
+		return nil
+	}
+	if symbol, ok := ctrlSymbols[r]; ok && mod&ModCtrl != 0 {
+		return &Event{Symbol: symbol, Mod: mod &^ ModCtrl}
+	}
+	return &Event{Symbol: Rune, Value: r, Mod: mod}
 }
 
-func readEvents(r io.Reader, out chan Event, next chan bool) {
+// parseParams parses the parameters of a control sequence separated by ";".
+// Missing or invalid parameters are 0.
+func parseParams(s string) []int {
+	if s == "" {
+		return nil
+	}
+	params := []int{}
+	for _, part := range strings.Split(s, ";") {
+		// Sub-parameters separated by ":" are ignored.
+		value, _ := strconv.Atoi(strings.SplitN(part, ":", 2)[0])
+		params = append(params, value)
+	}
+	return params
+}
+
+const (
+	// Time to wait for the rest of an escape sequence before an escape is
+	// taken as the Escape key.
+	escapeTimeout = 100 * time.Millisecond
+	// Maximum number of events read ahead of the event loop.
+	maxQueuedEvents = 64
+)
+
+// readEvents decodes keys from the input and sends them in batches of all
+// keys which are available, so that the event loop can handle keys which
+// were repeated while it was busy before rendering again. Input is only read
+// after the event loop has handled a batch and receives from next, so that
+// commands running other programs in the terminal can read it instead.
+func readEvents(r io.Reader, out chan []Event, next chan bool) {
+	buf := []byte{}
+	queue := []Event{}
+	in := make([]byte, 128)
 	for {
-		in := make([]byte, 128)
-		n, err := r.Read(in)
-		if err != nil {
-			return
+		timeout := time.Duration(-1)
+		if len(queue) > 0 {
+			timeout = 0
+		} else if len(buf) > 0 {
+			timeout = escapeTimeout
 		}
-		in = in[:n]
-		zap.L().Sugar().Debug("Input bytes: ", in)
+		ready := len(queue) < maxQueuedEvents && waitInput(r, timeout)
+		if ready {
+			n, err := r.Read(in)
+			if err != nil {
+				queue = append(queue, decodeEvents(buf, &buf, true, -1)...)
+				if len(queue) > 0 {
+					out <- queue
+					// Readers taking over, as after a replay, only
+					// start once the last events are handled.
+					<-next
+				}
+				return
+			}
+			zap.L().Sugar().Debug("Input bytes: ", in[:n])
+			buf = append(buf, in[:n]...)
+		}
+		// Keys which are still incomplete after the timeout are flushed.
+		flush := !ready && len(queue) == 0
+		queue = append(queue, decodeEvents(buf, &buf, flush, maxQueuedEvents-len(queue))...)
 
-		for len(in) > 0 {
+		if len(queue) > 0 && (!ready || len(queue) >= maxQueuedEvents) {
+			out <- queue
+			queue = []Event{}
+			<-next
+		}
+	}
+}
+
+// decodeEvents decodes up to max keys in the input, or all keys if max is
+// negative, storing the remaining input in rest. If flush is set, an escape
+// at the end of the input is the Escape key, and other incomplete keys are
+// dropped.
+func decodeEvents(in []byte, rest *[]byte, flush bool, max int) []Event {
+	events := []Event{}
+	for len(in) > 0 && len(events) != max {
+		event, size := decodeEvent(in)
+		if size == 0 {
+			// Pasted text may take longer to arrive than the timeout.
+			if !flush || bytes.HasPrefix(in, pasteStart) {
+				break
+			}
 			if in[0] == Escape {
-				// Literal escape or escape sequences.
-				switch {
-				case in[0] == Escape && (len(in) == 1 || in[1] == Escape):
-					out <- Event{Symbol: Escape}
-					in = in[1:]
-				case hasPrefix(in, []byte{27, 91, 65}):
-					out <- Event{Symbol: Up}
-					in = in[3:]
-				case hasPrefix(in, []byte{27, 91, 66}):
-					out <- Event{Symbol: Down}
-					in = in[3:]
-				case hasPrefix(in, []byte{27, 91, 67}):
-					out <- Event{Symbol: Right}
-					in = in[3:]
-				case hasPrefix(in, []byte{27, 91, 68}):
-					out <- Event{Symbol: Left}
-					in = in[3:]
-				case hasPrefix(in, []byte{27, 91, 49, 126}):
-					out <- Event{Symbol: Home}
-					in = in[4:]
-				case hasPrefix(in, []byte{27, 91, 52, 126}):
-					out <- Event{Symbol: End}
-					in = in[4:]
-				case hasPrefix(in, []byte{27, 91, 53, 126}):
-					out <- Event{Symbol: PgUp}
-					in = in[4:]
-				case hasPrefix(in, []byte{27, 91, 54, 126}):
-					out <- Event{Symbol: PgDown}
-					in = in[4:]
-				case hasPrefix(in, []byte{27, 91, 49, 59, 50, 65}):
-					out <- Event{Symbol: ShiftUp}
-					in = in[6:]
-				case hasPrefix(in, []byte{27, 91, 49, 59, 50, 66}):
-					out <- Event{Symbol: ShiftDown}
-					in = in[6:]
-				case hasPrefix(in, []byte{27, 91, 49, 59, 50, 67}):
-					out <- Event{Symbol: ShiftRight}
-					in = in[6:]
-				case hasPrefix(in, []byte{27, 91, 49, 59, 50, 68}):
-					out <- Event{Symbol: ShiftLeft}
-					in = in[6:]
-				case hasPrefix(in, []byte{27, 91, 49, 59, 53, 65}):
-					out <- Event{Symbol: CtrlUp}
-					in = in[6:]
-				case hasPrefix(in, []byte{27, 91, 49, 59, 53, 66}):
-					out <- Event{Symbol: CtrlDown}
-					in = in[6:]
-				case hasPrefix(in, []byte{27, 91, 49, 59, 53, 67}):
-					out <- Event{Symbol: CtrlRight}
-					in = in[6:]
-				case hasPrefix(in, []byte{27, 91, 49, 59, 53, 68}):
-					out <- Event{Symbol: CtrlLeft}
-					in = in[6:]
-				default:
-					// Unhandled entries.
-					in = in[0:0]
-				}
+				event, size = &Event{Symbol: Escape}, 1
 			} else {
-				r, rSize := utf8.DecodeRune(in)
-				switch {
-				case in[0] <= 31:
-					out <- Event{Symbol: EventSymbol(in[0])}
-					in = in[1:]
-				case in[0] == Del:
-					out <- Event{Symbol: Del}
-					in = in[1:]
-				case r != utf8.RuneError:
-					out <- Event{Symbol: Rune, Value: r}
-					in = in[rSize:]
-				default:
-					// Unhandled entries.
-					in = in[0:0]
-				}
+				size = len(in)
 			}
-			in = in[0:0] // Don't queue commands.
 		}
-		<-next
+		in = in[size:]
+		if event != nil {
+			events = append(events, *event)
+		}
+	}
+	*rest = append((*rest)[:0], in...)
+	return events
+}
+
+// waitInput waits until the input can be read without blocking, and returns
+// whether it can. A negative timeout waits indefinitely. Readers other than
+// files are assumed to only block at the end of their input.
+func waitInput(r io.Reader, timeout time.Duration) bool {
+	f, ok := r.(interface{ Fd() uintptr })
+	if !ok {
+		return timeout != 0
+	}
+	ms := -1
+	if timeout >= 0 {
+		ms = int(timeout / time.Millisecond)
+	}
+	fds := []sys.PollFd{{Fd: int32(f.Fd()), Events: sys.POLLIN}}
+	for {
+		n, err := sys.Poll(fds, ms)
+		if err == sys.EINTR {
+			continue
+		}
+		// Errors are reported when reading.
+		return err != nil || n > 0
 	}
 }
@@ -2,6 +2,8 @@ package terminal
 
 import (
 	"bytes"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,20 +16,24 @@ func testHelperReadEvent(
 	expectedEvents []Event,
 ) {
 	buffer := new(bytes.Buffer)
-	out := make(chan Event)
+	out := make(chan []Event)
 	next := make(chan bool)
 
 	buffer.WriteString(input)
 	go readEvents(buffer, out, next)
 
-	for _, expected := range expectedEvents {
+	events := []Event{}
+	for len(events) < len(expectedEvents) {
 		select {
-		case ev := <-out:
-			assert.Equal(t, expected, ev)
+		case batch := <-out:
+			events = append(events, batch...)
+			next <- true
 		case <-time.After(1 * time.Second):
 			assert.Fail(t, "Timeout")
+			return
 		}
 	}
+	assert.Equal(t, expectedEvents, events)
 }
 
 func TestReadTabEvent(t *testing.T) {
@@ -77,3 +83,199 @@ func TestReadUnknownEscapeCode(t *testing.T) {
 		[]Event{},
 	)
 }
+
+func TestReadModifiedEvents(t *testing.T) {
+	cases := map[string]Event{
+		"\x1b[1;2A":  {Symbol: Up, Mod: ModShift},
+		"\x1b[1;5D":  {Symbol: Left, Mod: ModCtrl},
+		"\x1b[1;3C":  {Symbol: Right, Mod: ModAlt},
+		"\x1b[5;7~":  {Symbol: PgUp, Mod: ModAlt | ModCtrl},
+		"\x1b[3~":    {Symbol: Delete},
+		"\x1b[2~":    {Symbol: Insert},
+		"\x1b[F":     {Symbol: End},
+		"\x1b[Z":     {Symbol: Tab, Mod: ModShift},
+		"\x1bOP":     {Symbol: F1},
+		"\x1bOH":     {Symbol: Home},
+		"\x1b[15~":   {Symbol: F5},
+		"\x1b[24;2~": {Symbol: F12, Mod: ModShift},
+		"\x1bx":      {Symbol: Rune, Value: 'x', Mod: ModAlt},
+		"\x1b😊":      {Symbol: Rune, Value: '😊', Mod: ModAlt},
+		"\x1b\x01":   {Symbol: CtrlA, Mod: ModAlt},
+		"\x1b[1;9H":  {Symbol: Home, Mod: ModMeta},
+	}
+	for input, expected := range cases {
+		ev, n := decodeEvent([]byte(input))
+		assert.Equal(t, &expected, ev, "%q", input)
+		assert.Equal(t, len(input), n, "%q", input)
+	}
+}
+
+func TestDecodeIncompleteEvent(t *testing.T) {
+	for _, input := range []string{"\x1b", "\x1b[", "\x1b[1;", "\x1bO", "\xf0\x9f"} {
+		ev, n := decodeEvent([]byte(input))
+		assert.Nil(t, ev, "%q", input)
+		assert.Equal(t, 0, n, "%q", input)
+	}
+}
+
+func TestDecodeUnknownEvent(t *testing.T) {
+	ev, n := decodeEvent([]byte("\x1b[99~a"))
+	assert.Nil(t, ev)
+	assert.Equal(t, 5, n)
+}
+
+func TestReadSplitEscapeCode(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	defer w.Close()
+	out := make(chan []Event)
+	next := make(chan bool)
+	go readEvents(r, out, next)
+
+	w.Write([]byte("\x1b[1;"))
+	time.Sleep(escapeTimeout / 2)
+	w.Write([]byte("2B"))
+	select {
+	case batch := <-out:
+		assert.Equal(t, []Event{{Symbol: Down, Mod: ModShift}}, batch)
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timeout")
+	}
+}
+
+func TestReadEscapeAfterTimeout(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	defer w.Close()
+	out := make(chan []Event)
+	next := make(chan bool)
+	go readEvents(r, out, next)
+
+	start := time.Now()
+	w.Write([]byte("\x1b"))
+	select {
+	case batch := <-out:
+		assert.Equal(t, []Event{{Symbol: Escape}}, batch)
+		assert.True(t, time.Since(start) >= escapeTimeout)
+		next <- true
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timeout")
+	}
+
+	// An escape sequence isn't taken apart.
+	w.Write([]byte("\x1bOA"))
+	select {
+	case batch := <-out:
+		assert.Equal(t, []Event{{Symbol: Up}}, batch)
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timeout")
+	}
+}
+
+func TestReadQueuedEvents(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	defer w.Close()
+	out := make(chan []Event)
+	next := make(chan bool)
+	go readEvents(r, out, next)
+
+	w.Write([]byte("k"))
+	select {
+	case batch := <-out:
+		assert.Equal(t, []Event{{Symbol: Rune, Value: 'k'}}, batch)
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timeout")
+	}
+	// Keys typed while the previous batch is handled are queued, and none
+	// are lost.
+	w.Write([]byte(strings.Repeat("j", 100) + "\x1b[A"))
+	time.Sleep(10 * time.Millisecond)
+	next <- true
+
+	events := []Event{}
+	for len(events) < 101 {
+		select {
+		case batch := <-out:
+			assert.True(t, len(batch) <= maxQueuedEvents)
+			events = append(events, batch...)
+			next <- true
+		case <-time.After(1 * time.Second):
+			assert.Fail(t, "Timeout")
+			return
+		}
+	}
+	assert.Equal(t, Event{Symbol: Rune, Value: 'j'}, events[0])
+	assert.Equal(t, Event{Symbol: Up}, events[100])
+}
+
+func TestDecodeKittyKeys(t *testing.T) {
+	cases := map[string]Event{
+		"\x1b[105;5u":   {Symbol: Rune, Value: 'i', Mod: ModCtrl},
+		"\x1b[109;5u":   {Symbol: Rune, Value: 'm', Mod: ModCtrl},
+		"\x1b[9u":       {Symbol: Tab},
+		"\x1b[13;5u":    {Symbol: Enter, Mod: ModCtrl},
+		"\x1b[27u":      {Symbol: Escape},
+		"\x1b[97;5u":    {Symbol: CtrlA},
+		"\x1b[97;7u":    {Symbol: CtrlA, Mod: ModAlt},
+		"\x1b[105;6u":   {Symbol: Rune, Value: 'i', Mod: ModCtrl | ModShift},
+		"\x1b[120;3u":   {Symbol: Rune, Value: 'x', Mod: ModAlt},
+		"\x1b[97;133u":  {Symbol: CtrlA},
+		"\x1b[97:65;6u": {Symbol: CtrlA, Mod: ModShift},
+		"\x1b[47;5u":    {Symbol: CtrlSlash},
+		"\x1b[1;5A":     {Symbol: Up, Mod: ModCtrl},
+	}
+	for input, expected := range cases {
+		ev, n := decodeEvent([]byte(input))
+		assert.Equal(t, &expected, ev, "%q", input)
+		assert.Equal(t, len(input), n, "%q", input)
+	}
+
+	// Keys without text, such as the keypad.
+	ev, n := decodeEvent([]byte("\x1b[57399u"))
+	assert.Nil(t, ev)
+	assert.Equal(t, 8, n)
+}
+
+func TestDecodePaste(t *testing.T) {
+	input := "\x1b[200~~/dir/file\r\x1b[A\x1b[201~j"
+	ev, n := decodeEvent([]byte(input))
+	assert.Equal(t, &Event{Symbol: Paste, Text: "~/dir/file\n\x1b[A"}, ev)
+	assert.Equal(t, len(input)-1, n)
+
+	// Pasted text isn't decoded until its end.
+	ev, n = decodeEvent([]byte("\x1b[200~text"))
+	assert.Nil(t, ev)
+	assert.Equal(t, 0, n)
+	rest := []byte{}
+	assert.Equal(t, []Event{}, decodeEvents([]byte("\x1b[200~text"), &rest, true, -1))
+	assert.Equal(t, "\x1b[200~text", string(rest))
+}
+
+func TestReadEventsWaitsForLastBatch(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	out := make(chan []Event)
+	next := make(chan bool)
+	done := make(chan struct{})
+	go func() {
+		readEvents(r, out, next)
+		close(done)
+	}()
+
+	w.Write([]byte("a"))
+	w.Close()
+	select {
+	case batch := <-out:
+		assert.Equal(t, []Event{{Symbol: Rune, Value: 'a'}}, batch)
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timeout")
+		return
+	}
+	select {
+	case next <- true:
+	case <-time.After(1 * time.Second):
+		assert.Fail(t, "Timeout")
+	}
+	<-done
+}
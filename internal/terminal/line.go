@@ -11,8 +11,8 @@ import (
 var escapeRegex, graphicsEscapeRegex *regexp.Regexp
 
 func init() {
-	escapeRegex = regexp.MustCompile("\x1b\\[[0-9;]*[a-zA-Z]")
-	graphicsEscapeRegex = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	escapeRegex = regexp.MustCompile("\x1b\\[[0-9;:]*[a-zA-Z]")
+	graphicsEscapeRegex = regexp.MustCompile("\x1b\\[[0-9;:]*m")
 }
 
 type Line interface {
@@ -26,24 +26,51 @@ type line struct {
 	line            strings.Builder
 	length          int
 	maxLength       int
+	offset          int
+	skipped         int
 	defaultGraphics *Graphics
 }
 
 func NewLine(defaultGraphics *Graphics, maxLength int) Line {
-	return &line{defaultGraphics: defaultGraphics, maxLength: maxLength}
+	return NewOffsetLine(defaultGraphics, maxLength, 0)
+}
+
+// NewOffsetLine creates a line which skips the first columns of the appended
+// text. Escape codes in the skipped part are still applied.
+func NewOffsetLine(defaultGraphics *Graphics, maxLength int, offset int) Line {
+	return &line{defaultGraphics: defaultGraphics, maxLength: maxLength, offset: offset}
+}
+
+// runeWidth returns the number of columns taken up by a rune, or 0 if it is
+// not displayed.
+func runeWidth(r rune) int {
+	if r == utf8.RuneError || unicode.IsMark(r) || unicode.IsControl(r) {
+		return 0
+	}
+	runeKind := width.LookupRune(r).Kind()
+	if runeKind == width.EastAsianWide || runeKind == width.EastAsianFullwidth {
+		return 2
+	}
+	return 1
 }
 
 func (l *line) appendText(s string) {
 	for len(s) > 0 && l.length < l.maxLength {
 		r, size := utf8.DecodeRuneInString(s)
 		s = s[size:]
-		if r == utf8.RuneError || unicode.IsMark(r) || unicode.IsControl(r) {
+		termWidth := runeWidth(r)
+		if termWidth == 0 {
 			continue
 		}
-		termWidth := 1
-		runeKind := width.LookupRune(r).Kind()
-		if runeKind == width.EastAsianWide || runeKind == width.EastAsianFullwidth {
-			termWidth = 2
+		if l.skipped < l.offset {
+			l.skipped += termWidth
+			if l.skipped > l.offset {
+				// Wide rune cut off by the offset.
+				l.skipped = l.offset
+				l.length += 1
+				l.line.WriteString(" ")
+			}
+			continue
 		}
 		if l.length+termWidth <= l.maxLength {
 			l.length += termWidth
@@ -98,3 +125,33 @@ func (l *line) Length() int {
 func (l *line) Text() string {
 	return l.line.String()
 }
+
+// TextWidth returns the number of columns taken up by a string which may
+// contain escape codes.
+func TextWidth(s string) int {
+	total := 0
+	for _, r := range escapeRegex.ReplaceAllString(s, "") {
+		total += runeWidth(r)
+	}
+	return total
+}
+
+// WrapOffsets returns the column offsets at which to split a string which may
+// contain escape codes into lines of the given width. Wide runes are not
+// split across lines.
+func WrapOffsets(s string, width int) []int {
+	offsets := []int{0}
+	if width <= 0 {
+		return offsets
+	}
+	start, col := 0, 0
+	for _, r := range escapeRegex.ReplaceAllString(s, "") {
+		w := runeWidth(r)
+		if w > 0 && col+w-start > width {
+			start = col
+			offsets = append(offsets, start)
+		}
+		col += w
+	}
+	return offsets
+}
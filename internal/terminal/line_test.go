@@ -50,3 +50,38 @@ func TestLineWideUnicode(t *testing.T) {
 	line.Append("😊", nil)
 	assert.Equal(t, 2, line.Length())
 }
+
+func TestLineOffset(t *testing.T) {
+	line := NewOffsetLine(
+		&Graphics{Reverse: true},
+		2,
+		2,
+	)
+
+	line.AppendRaw("\x1b[44mabcde")
+	assert.Equal(t, 2, line.Length())
+	assert.Equal(t, "\x1b[44mcd\x1b[m\x1b[7m", line.Text())
+}
+
+func TestLineOffsetWideUnicode(t *testing.T) {
+	line := NewOffsetLine(
+		&Graphics{Reverse: true},
+		3,
+		1,
+	)
+
+	line.Append("😊a", nil)
+	assert.Equal(t, 2, line.Length())
+	assert.Equal(t, " a\x1b[m\x1b[7m", line.Text())
+}
+
+func TestTextWidth(t *testing.T) {
+	assert.Equal(t, 5, TextWidth("\x1b[1mab\x1b[m😊c"))
+}
+
+func TestWrapOffsets(t *testing.T) {
+	assert.Equal(t, []int{0}, WrapOffsets("", 3))
+	assert.Equal(t, []int{0, 3, 6}, WrapOffsets("\x1b[1mabcdefg", 3))
+	assert.Equal(t, []int{0, 2}, WrapOffsets("ab😊c", 3))
+	assert.Equal(t, []int{0, 2, 5}, WrapOffsets("ab😊cd", 3))
+}
@@ -0,0 +1,409 @@
+package terminal
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// style is the graphics state of a cell.
+type style struct {
+	fg        Color
+	bg        Color
+	ul        Color
+	attrs     Attributes
+	underline UnderlineStyle
+}
+
+// sgr returns the escape code which changes the previous style to the style.
+// Only the parameters that differ are set, and a full reset is only used to
+// return to the default style.
+func (s style) sgr(prev style) string {
+	if s == prev {
+		return ""
+	}
+	if s == (style{}) {
+		return resetGraphics
+	}
+	codes := []string{}
+	// Attributes are turned off first, since ending bold text also ends dim
+	// text.
+	attrs := prev.attrs
+	for _, a := range sgrAttributes {
+		if attrs&a.attr != 0 && s.attrs&a.attr == 0 {
+			codes = append(codes, strconv.Itoa(a.off))
+			for _, b := range sgrAttributes {
+				if b.off == a.off {
+					attrs &^= b.attr
+				}
+			}
+		}
+	}
+	for _, a := range sgrAttributes {
+		if s.attrs&a.attr != 0 && attrs&a.attr == 0 {
+			codes = append(codes, strconv.Itoa(a.on))
+		}
+	}
+	if s.underline != prev.underline {
+		if s.underline == NoUnderline {
+			codes = append(codes, "24")
+		} else {
+			codes = append(codes, s.underline.code())
+		}
+	}
+	if s.fg != prev.fg {
+		if s.fg == nil {
+			codes = append(codes, "39")
+		} else {
+			codes = append(codes, s.fg.FgCode())
+		}
+	}
+	if s.bg != prev.bg {
+		if s.bg == nil {
+			codes = append(codes, "49")
+		} else {
+			codes = append(codes, s.bg.BgCode())
+		}
+	}
+	if s.ul != prev.ul {
+		if s.ul == nil {
+			codes = append(codes, "59")
+		} else {
+			codes = append(codes, s.ul.UnderlineCode())
+		}
+	}
+	return csi + strings.Join(codes, ";") + "m"
+}
+
+// apply updates the style with the parameters of an SGR escape code.
+// Parameters which aren't understood are ignored.
+func (s *style) apply(params string) {
+	parts := strings.Split(params, ";")
+	for i := 0; i < len(parts); i++ {
+		// Sub-parameters are separated by colons, as in "4:3" or
+		// "38:2::255:0:0".
+		subParts := strings.Split(parts[i], ":")
+		code, err := strconv.Atoi(subParts[0])
+		if err != nil && subParts[0] != "" {
+			continue
+		}
+		switch {
+		case code == 0:
+			*s = style{}
+		case code == 4:
+			s.underline = SingleUnderline
+			if len(subParts) > 1 {
+				if value, err := strconv.Atoi(subParts[1]); err == nil && value <= int(DashedUnderline) {
+					s.underline = UnderlineStyle(value)
+				}
+			}
+		case code == 6:
+			// Rapid blinking isn't told apart from blinking.
+			s.attrs |= AttrBlink
+		case code == 21:
+			s.underline = DoubleUnderline
+		case code == 24:
+			s.underline = NoUnderline
+		case code >= 30 && code <= 37:
+			s.fg = Color3Bit{Value: code - 30}
+		case code >= 40 && code <= 47:
+			s.bg = Color3Bit{Value: code - 40}
+		case code >= 90 && code <= 97:
+			s.fg = Color3Bit{Value: code - 90, Bright: true}
+		case code >= 100 && code <= 107:
+			s.bg = Color3Bit{Value: code - 100, Bright: true}
+		case code == 39:
+			s.fg = nil
+		case code == 49:
+			s.bg = nil
+		case code == 59:
+			s.ul = nil
+		case code == 38 || code == 48 || code == 58:
+			var color Color
+			if len(subParts) > 1 {
+				color = parseColonColor(subParts[1:])
+			} else {
+				var n int
+				color, n = parseExtendedColor(parts[i+1:])
+				i += n
+			}
+			if color == nil {
+				continue
+			}
+			switch code {
+			case 38:
+				s.fg = color
+			case 48:
+				s.bg = color
+			default:
+				s.ul = color
+			}
+		default:
+			for _, a := range sgrAttributes {
+				if code == a.on {
+					s.attrs |= a.attr
+				} else if code == a.off {
+					s.attrs &^= a.attr
+				}
+			}
+		}
+	}
+}
+
+// parseExtendedColor parses the parameters of a 256 or 24-bit color following
+// 38, 48 or 58. It returns the color, or nil if it is invalid, and the number
+// of parameters used.
+func parseExtendedColor(parts []string) (Color, int) {
+	values := []int{}
+	for _, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 || value > 255 {
+			break
+		}
+		values = append(values, value)
+	}
+	switch {
+	case len(values) >= 2 && values[0] == 5:
+		return Color8Bit{Value: values[1]}, 2
+	case len(values) >= 4 && values[0] == 2:
+		return Color24Bit{R: values[1], G: values[2], B: values[3]}, 4
+	}
+	return nil, len(values)
+}
+
+// parseColonColor parses the sub-parameters of a 256 or 24-bit color, as in
+// "5:n", "2:r:g:b" or "2:id:r:g:b" with a color space identifier.
+func parseColonColor(parts []string) Color {
+	if len(parts) == 5 && parts[0] == "2" {
+		parts = append(parts[:1], parts[2:]...)
+	}
+	color, n := parseExtendedColor(parts)
+	if n != len(parts) {
+		return nil
+	}
+	return color
+}
+
+// cell is a position on the screen. Wide runes take up two cells, the second
+// of which has a width of 0.
+type cell struct {
+	r     rune
+	width int
+	style style
+}
+
+var blankCell = cell{r: ' ', width: 1}
+
+// screen is a buffer of the cells of the terminal, in which the views are
+// rendered before writing the differences with the previous frame.
+type screen struct {
+	rows  int
+	cols  int
+	cells [][]cell
+}
+
+func newScreen(rows int, cols int) *screen {
+	s := &screen{rows: rows, cols: cols, cells: make([][]cell, rows)}
+	for i := range s.cells {
+		s.cells[i] = make([]cell, cols)
+		for j := range s.cells[i] {
+			s.cells[i][j] = blankCell
+		}
+	}
+	return s
+}
+
+// set sets the cell at a row and column starting from 1, clearing wide runes
+// which are overwritten in part.
+func (s *screen) set(row int, col int, c cell) {
+	if row < 1 || row > s.rows || col < 1 || col+c.width-1 > s.cols {
+		return
+	}
+	cells := s.cells[row-1]
+	i, last := col-1, col+c.width-2
+	if cells[i].width == 0 && i > 0 {
+		cells[i-1] = cell{r: ' ', width: 1, style: cells[i-1].style}
+	}
+	if cells[last].width == 2 && last+1 < s.cols {
+		cells[last+1] = cell{r: ' ', width: 1, style: cells[last+1].style}
+	}
+	cells[i] = c
+	if c.width == 2 {
+		cells[i+1] = cell{style: c.style}
+	}
+}
+
+// setText renders text containing SGR escape codes, such as the text of a
+// Line, at a row and column. The rest of the given number of columns is
+// filled with blanks in the style at the end of the text.
+func (s *screen) setText(row int, col int, cols int, text string) {
+	current := style{}
+	end := col + cols
+	for len(text) > 0 && col < end {
+		if loc := escapeRegex.FindStringIndex(text); loc != nil && loc[0] == 0 {
+			code := text[:loc[1]]
+			if strings.HasSuffix(code, "m") {
+				current.apply(code[len(csi) : len(code)-1])
+			}
+			text = text[loc[1]:]
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		w := runeWidth(r)
+		if w == 0 || col+w > end {
+			continue
+		}
+		s.set(row, col, cell{r: r, width: w, style: current})
+		col += w
+	}
+	for ; col < end; col++ {
+		s.set(row, col, cell{r: ' ', width: 1, style: current})
+	}
+}
+
+// setBorder draws a box around a position.
+func (s *screen) setBorder(p Position) {
+	if p.Rows < 2 || p.Cols < 2 {
+		return
+	}
+	top, bottom := p.Top, p.Top+p.Rows-1
+	left, right := p.Left, p.Left+p.Cols-1
+	for col := left + 1; col < right; col++ {
+		s.set(top, col, cell{r: '─', width: 1})
+		s.set(bottom, col, cell{r: '─', width: 1})
+	}
+	for row := top + 1; row < bottom; row++ {
+		s.set(row, left, cell{r: '│', width: 1})
+		s.set(row, right, cell{r: '│', width: 1})
+	}
+	s.set(top, left, cell{r: '┌', width: 1})
+	s.set(top, right, cell{r: '┐', width: 1})
+	s.set(bottom, left, cell{r: '└', width: 1})
+	s.set(bottom, right, cell{r: '┘', width: 1})
+}
+
+// Unchanged cells between changes in a row which are written again instead of
+// moving the cursor over them.
+const maxRewrittenCells = 4
+
+// screenWriter writes cells to the terminal, keeping track of the cursor
+// position and style.
+type screenWriter struct {
+	out   strings.Builder
+	cols  int
+	row   int
+	col   int
+	style style
+}
+
+func (w *screenWriter) moveTo(row int, col int) {
+	if row > w.row {
+		w.out.WriteString(cursorDown(row - w.row))
+	} else if row < w.row {
+		w.out.WriteString(cursorUp(w.row - row))
+	}
+	if col == 1 && w.col != 1 {
+		w.out.WriteString("\r")
+	} else if col > w.col {
+		w.out.WriteString(cursorForward(col - w.col))
+	} else if col < w.col {
+		w.out.WriteString(cursorBack(w.col - col))
+	}
+	w.row, w.col = row, col
+}
+
+func (w *screenWriter) setStyle(s style) {
+	if s != w.style {
+		w.out.WriteString(s.sgr(w.style))
+		w.style = s
+	}
+}
+
+// write writes the cells starting at the cursor. The cursor stays at the last
+// column once it is reached, since wrapping is disabled.
+func (w *screenWriter) write(cells []cell) {
+	for _, c := range cells {
+		if c.width == 0 {
+			continue
+		}
+		w.setStyle(c.style)
+		w.out.WriteRune(c.r)
+		w.col += c.width
+	}
+	if w.col > w.cols {
+		w.col = w.cols
+	}
+}
+
+// diff returns the output which changes the terminal from showing the previous
+// frame to showing the screen. The cursor is expected at the top left with the
+// default style before and after the output. Without a previous frame of the
+// same size, every row is written, moving down with newlines so that the
+// terminal scrolls if needed.
+func (s *screen) diff(prev *screen) string {
+	w := &screenWriter{cols: s.cols, row: 1, col: 1}
+	if prev == nil || prev.rows != s.rows || prev.cols != s.cols {
+		for row := 1; row <= s.rows; row++ {
+			if row > 1 {
+				w.setStyle(style{})
+				w.out.WriteString("\r\n")
+				w.row, w.col = row, 1
+			}
+			w.write(s.cells[row-1])
+		}
+	} else {
+		for row := 1; row <= s.rows; row++ {
+			for _, run := range changedRuns(prev.cells[row-1], s.cells[row-1]) {
+				w.moveTo(row, run[0]+1)
+				w.write(s.cells[row-1][run[0]:run[1]])
+			}
+		}
+	}
+	w.setStyle(style{})
+	w.moveTo(1, 1)
+	return w.out.String()
+}
+
+// changedRuns returns the ranges of cells which differ between two rows.
+// Ranges don't start or end within wide runes, and are merged if only a few
+// cells are between them.
+func changedRuns(prev []cell, cells []cell) [][2]int {
+	runs := [][2]int{}
+	for i := 0; i < len(cells); i++ {
+		if cells[i] == prev[i] {
+			continue
+		}
+		start := i
+		for start > 0 && (cells[start].width == 0 || prev[start].width == 0) {
+			start--
+		}
+		end := i + 1
+		for end < len(cells) && (cells[end].width == 0 || prev[end].width == 0) {
+			end++
+		}
+		if len(runs) > 0 && start-runs[len(runs)-1][1] <= maxRewrittenCells {
+			runs[len(runs)-1][1] = end
+		} else {
+			runs = append(runs, [2]int{start, end})
+		}
+		i = end - 1
+	}
+	return runs
+}
+
+// String returns the text of the screen without graphics, with trailing
+// blanks removed from each row.
+func (s *screen) String() string {
+	rows := []string{}
+	for _, cells := range s.cells {
+		row := &strings.Builder{}
+		for _, c := range cells {
+			if c.width > 0 {
+				row.WriteRune(c.r)
+			}
+		}
+		rows = append(rows, strings.TrimRight(row.String(), " "))
+	}
+	return strings.Join(rows, "\n")
+}
@@ -0,0 +1,115 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreenSetText(t *testing.T) {
+	s := newScreen(2, 6)
+	line := NewLine(&Graphics{}, 6)
+	line.Append("a", &Graphics{Bold: true})
+	line.Append("😊b", &Graphics{FgColor: Color8Bit{Value: 240}})
+	s.setText(1, 1, 6, line.Text())
+	s.setText(2, 2, 3, "\x1b[7mxyzw")
+
+	assert.Equal(t, "a😊b\n xyz", s.String())
+	cells := s.cells[0]
+	assert.Equal(t, cell{r: 'a', width: 1, style: style{attrs: AttrBold}}, cells[0])
+	assert.Equal(t, cell{r: '😊', width: 2, style: style{fg: Color8Bit{Value: 240}}}, cells[1])
+	assert.Equal(t, 0, cells[2].width)
+	assert.Equal(t, blankCell, cells[5])
+	// Blanks after the text keep its style.
+	assert.Equal(t, cell{r: ' ', width: 1}, s.cells[1][0])
+	assert.Equal(t, cell{r: 'z', width: 1, style: style{attrs: AttrReverse}}, s.cells[1][3])
+	assert.Equal(t, blankCell, s.cells[1][4])
+}
+
+func TestScreenOverwriteWideRune(t *testing.T) {
+	s := newScreen(1, 4)
+	s.setText(1, 1, 4, "😊😊")
+	s.set(1, 2, cell{r: 'a', width: 1})
+	assert.Equal(t, " a😊", s.String())
+	s.set(1, 3, cell{r: 'b', width: 1})
+	assert.Equal(t, " ab", s.String())
+}
+
+func TestStyleApply(t *testing.T) {
+	s := style{}
+	s.apply("1;3;38;2;1;2;3;104")
+	assert.Equal(t, style{
+		fg:    Color24Bit{R: 1, G: 2, B: 3},
+		bg:    Color3Bit{Value: 4, Bright: true},
+		attrs: AttrBold | AttrItalic,
+	}, s)
+	assert.Equal(t, "\x1b[1;3;38;2;1;2;3;104m", s.sgr(style{}))
+	s.apply("22;23;39")
+	assert.Equal(t, style{bg: Color3Bit{Value: 4, Bright: true}}, s)
+	s.apply("")
+	assert.Equal(t, style{}, s)
+	assert.Equal(t, "", s.sgr(style{}))
+}
+
+func TestStyleApplyUnderline(t *testing.T) {
+	s := style{}
+	s.apply("4:3;58:2::255:0:0")
+	assert.Equal(t, style{underline: CurlyUnderline, ul: Color24Bit{R: 255}}, s)
+	assert.Equal(t, "\x1b[4:3;58;2;255;0;0m", s.sgr(style{}))
+	s.apply("21;58;5;9")
+	assert.Equal(t, style{underline: DoubleUnderline, ul: Color8Bit{Value: 9}}, s)
+	s.apply("24;59")
+	assert.Equal(t, style{}, s)
+}
+
+func TestStyleSGRChanges(t *testing.T) {
+	prev := style{fg: Color3Bit{Value: 1}, attrs: AttrBold}
+	// Only the attribute which changes is set.
+	assert.Equal(t, "\x1b[3m", style{fg: Color3Bit{Value: 1}, attrs: AttrBold | AttrItalic}.sgr(prev))
+	assert.Equal(t, "\x1b[39m", style{attrs: AttrBold}.sgr(prev))
+	// Bold and dim text are ended by the same code.
+	assert.Equal(t, "\x1b[22;2m", style{fg: Color3Bit{Value: 1}, attrs: AttrDim}.sgr(prev))
+	underlined := style{underline: SingleUnderline, bg: Color8Bit{Value: 3}, ul: Color8Bit{Value: 3}, attrs: AttrBold}
+	assert.Equal(t, "\x1b[24;49;59m", style{attrs: AttrBold}.sgr(underlined))
+	assert.Equal(t, resetGraphics, style{}.sgr(prev))
+	assert.Equal(t, "", prev.sgr(prev))
+}
+
+func TestScreenDiffFull(t *testing.T) {
+	s := newScreen(2, 3)
+	s.setText(1, 1, 3, "ab")
+	s.setText(2, 1, 3, "\x1b[1mc")
+	assert.Equal(t, "ab \r\n\x1b[1mc  \x1b[m\x1b[1A\r", s.diff(nil))
+}
+
+func TestScreenDiff(t *testing.T) {
+	prev := newScreen(3, 10)
+	prev.setText(1, 1, 10, "same")
+	prev.setText(2, 1, 10, "abcdefghij")
+	prev.setText(3, 1, 10, "same")
+
+	s := newScreen(3, 10)
+	s.setText(1, 1, 10, "same")
+	s.setText(2, 1, 10, "abXdefghiY")
+	s.setText(3, 1, 10, "same")
+	// Only the changed cells are written.
+	assert.Equal(t, "\x1b[1B\x1b[2CX\x1b[6CY\x1b[1A\r", s.diff(prev))
+
+	// Nearby changes are written together.
+	s.setText(2, 1, 10, "abXdYfghij")
+	assert.Equal(t, "\x1b[1B\x1b[2CXdY\x1b[1A\r", s.diff(prev))
+
+	// Identical lines at other rows are still written.
+	s.setText(2, 1, 10, "same")
+	assert.Equal(t, "\x1b[1Bsame      \x1b[1A\r", s.diff(prev))
+
+	assert.Equal(t, "", prev.diff(prev))
+}
+
+func TestScreenDiffWideRune(t *testing.T) {
+	prev := newScreen(1, 4)
+	prev.setText(1, 1, 4, "a😊")
+	s := newScreen(1, 4)
+	s.setText(1, 1, 4, "a😁")
+	assert.Equal(t, "\x1b[1C😁\r", s.diff(prev))
+}
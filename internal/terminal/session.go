@@ -0,0 +1,249 @@
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A session is a log of the input and size of a terminal, with one entry per
+// line:
+//
+//	<milliseconds> size <rows> <cols>
+//	<milliseconds> input <quoted bytes>
+//
+// Lines starting with "#" are comments.
+type sessionEntry struct {
+	time  time.Duration
+	input []byte
+	rows  int
+	cols  int
+}
+
+// sessionRecorder writes a session as it happens.
+type sessionRecorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	rows  int
+	cols  int
+}
+
+func newSessionRecorder(w io.Writer) *sessionRecorder {
+	fmt.Fprintln(w, "# twf session")
+	return &sessionRecorder{w: w, start: time.Now()}
+}
+
+func (r *sessionRecorder) elapsed() int64 {
+	return int64(time.Since(r.start) / time.Millisecond)
+}
+
+func (r *sessionRecorder) input(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintln(r.w, r.elapsed(), "input", strconv.Quote(string(p)))
+}
+
+// size records the size of the terminal if it changed.
+func (r *sessionRecorder) size(rows int, cols int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rows == r.rows && cols == r.cols {
+		return
+	}
+	r.rows, r.cols = rows, cols
+	fmt.Fprintln(r.w, r.elapsed(), "size", rows, cols)
+}
+
+// recordingReader records the input read from a file. Its descriptor can be
+// polled like that of the file.
+type recordingReader struct {
+	f        *os.File
+	recorder *sessionRecorder
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if n > 0 {
+		r.recorder.input(p[:n])
+	}
+	return n, err
+}
+
+func (r *recordingReader) Fd() uintptr {
+	return r.f.Fd()
+}
+
+// readSession parses a session.
+func readSession(r io.Reader) ([]sessionEntry, error) {
+	entries := []sessionEntry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry, err := parseSessionEntry(line)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d of session: %w", lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func parseSessionEntry(line string) (sessionEntry, error) {
+	entry := sessionEntry{}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 {
+		return entry, fmt.Errorf("Unexpected entry: %s", line)
+	}
+	ms, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return entry, err
+	}
+	entry.time = time.Duration(ms) * time.Millisecond
+	switch parts[1] {
+	case "input":
+		input, err := strconv.Unquote(parts[2])
+		if err != nil {
+			return entry, fmt.Errorf("Unexpected input: %s", parts[2])
+		}
+		entry.input = []byte(input)
+	case "size":
+		_, err := fmt.Sscan(parts[2], &entry.rows, &entry.cols)
+		if err != nil || entry.rows <= 0 || entry.cols <= 0 {
+			return entry, fmt.Errorf("Unexpected size: %s", parts[2])
+		}
+	default:
+		return entry, fmt.Errorf("Unexpected entry: %s", line)
+	}
+	return entry, nil
+}
+
+// replayBackend replays the input and sizes of a session, showing the output
+// on another backend. Once the session is over, input is read from the other
+// backend, or ends.
+type replayBackend struct {
+	Backend
+	entries []sessionEntry
+	// Whether to read input from the other backend after the session.
+	takeOver bool
+	mu       sync.Mutex
+	rows     int
+	cols     int
+	resized  chan struct{}
+	closed   chan struct{}
+}
+
+// Replay replays a session on a backend. If takeOver is set, input is read
+// from the backend once the session is over. Otherwise, input ends with the
+// session, which ends the event loop.
+func Replay(r io.Reader, out Backend, takeOver bool) (Backend, error) {
+	entries, err := readSession(r)
+	if err != nil {
+		return nil, err
+	}
+	b := &replayBackend{
+		Backend:  out,
+		entries:  entries,
+		takeOver: takeOver,
+		resized:  make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+	}
+	b.rows, b.cols, err = out.Size()
+	if err != nil {
+		return nil, err
+	}
+	// Start with the first recorded size.
+	for _, entry := range entries {
+		if entry.rows > 0 {
+			b.setSize(entry.rows, entry.cols)
+			break
+		}
+	}
+	return b, nil
+}
+
+// setSize sets the size reported for the session. Virtual terminals are
+// resized to it, while it is clamped to the actual size of other terminals,
+// since the output must fit on them.
+func (b *replayBackend) setSize(rows int, cols int) {
+	if v, ok := b.Backend.(*VirtualBackend); ok {
+		v.resize(rows, cols)
+	} else if actualRows, actualCols, err := b.Backend.Size(); err == nil {
+		if rows > actualRows {
+			rows = actualRows
+		}
+		if cols > actualCols {
+			cols = actualCols
+		}
+	}
+	b.mu.Lock()
+	b.rows, b.cols = rows, cols
+	b.mu.Unlock()
+}
+
+func (b *replayBackend) Size() (int, int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rows, b.cols, nil
+}
+
+func (b *replayBackend) Resized() <-chan struct{} {
+	return b.resized
+}
+
+func (b *replayBackend) ReadEvents(out chan []Event, next chan bool) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		readEvents(r, out, next)
+		close(done)
+	}()
+
+	start := time.Now()
+	for _, entry := range b.entries {
+		select {
+		case <-time.After(time.Until(start.Add(entry.time))):
+		case <-b.closed:
+			w.Close()
+			return
+		}
+		if entry.input != nil {
+			w.Write(entry.input)
+		} else {
+			b.setSize(entry.rows, entry.cols)
+			select {
+			case b.resized <- struct{}{}:
+			default:
+			}
+		}
+	}
+	w.Close()
+	select {
+	case <-done:
+		r.Close()
+	case <-b.closed:
+		return
+	}
+	if !b.takeOver {
+		close(out)
+		return
+	}
+	b.Backend.ReadEvents(out, next)
+}
+
+func (b *replayBackend) Close() error {
+	close(b.closed)
+	return b.Backend.Close()
+}
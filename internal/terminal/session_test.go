@@ -0,0 +1,191 @@
+package terminal
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	recorder := newSessionRecorder(buffer)
+	recorder.size(24, 80)
+	recorder.size(24, 80)
+	recorder.input([]byte("j\x1b[A\"😊"))
+	recorder.size(10, 40)
+
+	entries, err := readSession(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, 24, entries[0].rows)
+	assert.Equal(t, 80, entries[0].cols)
+	assert.Equal(t, "j\x1b[A\"😊", string(entries[1].input))
+	assert.Equal(t, 10, entries[2].rows)
+	assert.Equal(t, 40, entries[2].cols)
+}
+
+func TestReadSessionError(t *testing.T) {
+	_, err := readSession(strings.NewReader("0 size 24 80\n5 input j\n"))
+	assert.EqualError(t, err, "Line 2 of session: Unexpected input: j")
+}
+
+// logView shows the commands it ran, one per line.
+type logView struct {
+	log []string
+}
+
+func (v *logView) Position(rows int, cols int) Position {
+	return Position{Top: 1, Left: 1, Rows: rows, Cols: cols}
+}
+
+func (v *logView) HasBorder() bool    { return false }
+func (v *logView) ShouldRender() bool { return true }
+
+func (v *logView) Render(p Position) []Line {
+	lines := []Line{NewLine(&Graphics{}, p.Cols).Append(
+		strings.Repeat("-", p.Cols), nil,
+	)}
+	for _, entry := range v.log {
+		lines = append(lines, NewLine(&Graphics{}, p.Cols).Append(entry, nil))
+	}
+	return lines
+}
+
+func (v *logView) GetCommands() map[string]Command {
+	commands := map[string]Command{}
+	for _, name := range []string{"down", "up", "escape"} {
+		name := name
+		commands[name] = func(helper TerminalHelper, args ...interface{}) error {
+			v.log = append(v.log, name)
+			return nil
+		}
+	}
+	return commands
+}
+
+func TestReplay(t *testing.T) {
+	session := strings.Join([]string{
+		"# twf session",
+		"0 size 6 10",
+		"10 input \"j\"",
+		"20 input \"\\x1b[A\\x1b\"",
+		"30 size 5 8",
+		"200 input \"q\"",
+	}, "\n")
+	virtual := NewVirtualBackend(24, 80)
+	backend, err := Replay(strings.NewReader(session), virtual, true)
+	assert.Nil(t, err)
+	rows, cols, _ := backend.Size()
+	assert.Equal(t, 6, rows)
+	assert.Equal(t, 10, cols)
+
+	term, err := NewTerm(&TerminalConfig{Height: 1}, backend)
+	assert.Nil(t, err)
+	view := &logView{}
+	bindings := map[string][]string{
+		(&Event{Symbol: Rune, Value: 'j'}).HashKey(): {"down"},
+		(&Event{Symbol: Up}).HashKey():               {"up"},
+		(&Event{Symbol: Escape}).HashKey():           {"escape"},
+		(&Event{Symbol: Rune, Value: 'q'}).HashKey(): {"quit"},
+	}
+	done := make(chan error)
+	go func() {
+		done <- term.StartLoop(bindings, []View{view})
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Timeout")
+	}
+
+	assert.Equal(t, []string{"down", "up", "escape"}, view.log)
+	assert.Equal(t, "--------\ndown\nup\nescape\n", virtual.Screen())
+	term.Close()
+}
+
+func TestReplayHeadless(t *testing.T) {
+	_, err := OpenTerm(&TerminalConfig{Height: 1, Headless: true})
+	assert.NotNil(t, err)
+
+	f, err := ioutil.TempFile("", "twf_")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("0 size 4 10\n10 input \"jq\"\n")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	term, err := OpenTerm(&TerminalConfig{Height: 1, Headless: true, Replay: f.Name()})
+	assert.Nil(t, err)
+	view := &logView{}
+	bindings := map[string][]string{
+		(&Event{Symbol: Rune, Value: 'j'}).HashKey(): {"down"},
+		(&Event{Symbol: Rune, Value: 'q'}).HashKey(): {"quit"},
+	}
+	done := make(chan error)
+	go func() {
+		done <- term.StartLoop(bindings, []View{view})
+	}()
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Timeout")
+	}
+	assert.Equal(t, []string{"down"}, view.log)
+	term.Close()
+}
+
+func TestReplayHeadlessWithoutQuit(t *testing.T) {
+	f, err := ioutil.TempFile("", "twf_")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("0 size 4 10\n10 input \"jj\"\n")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Close())
+
+	term, err := OpenTerm(&TerminalConfig{Height: 1, Headless: true, Replay: f.Name()})
+	assert.Nil(t, err)
+	view := &logView{}
+	bindings := map[string][]string{
+		(&Event{Symbol: Rune, Value: 'j'}).HashKey(): {"down"},
+	}
+	done := make(chan error)
+	go func() {
+		done <- term.StartLoop(bindings, []View{view})
+	}()
+	// The loop ends with the session.
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "Timeout")
+	}
+	assert.Equal(t, []string{"down", "down"}, view.log)
+	term.Close()
+}
+
+func TestReplayClampsSize(t *testing.T) {
+	// A backend which can't be resized, like a terminal.
+	out := struct{ Backend }{NewVirtualBackend(24, 80)}
+	backend, err := Replay(strings.NewReader("0 size 30 100\n"), out, true)
+	assert.Nil(t, err)
+	rows, cols, _ := backend.Size()
+	assert.Equal(t, 24, rows)
+	assert.Equal(t, 80, cols)
+
+	replay := backend.(*replayBackend)
+	replay.setSize(6, 10)
+	rows, cols, _ = backend.Size()
+	assert.Equal(t, 6, rows)
+	assert.Equal(t, 10, cols)
+	replay.setSize(6, 200)
+	rows, cols, _ = backend.Size()
+	assert.Equal(t, 6, rows)
+	assert.Equal(t, 80, cols)
+}
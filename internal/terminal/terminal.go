@@ -1,134 +1,153 @@
 package terminal
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime/debug"
-	"strings"
 
 	"go.uber.org/zap"
-	"golang.org/x/crypto/ssh/terminal"
 	sys "golang.org/x/sys/unix"
 )
 
 type Terminal struct {
 	config          *TerminalConfig
-	originalState   terminal.State
+	backend         Backend
 	insertedNewline bool
-	previousRender  map[string]bool
-	rows            int
-	cols            int
-	in              *os.File
-	out             *os.File
-	loop            bool
-	currentRow      int
+	// Last frame written to the terminal, nil if it must be written again
+	// in full.
+	previousRender *screen
+	rows           int
+	cols           int
+	loop           bool
+	tasks          chan func() error
+	done           chan struct{}
 }
 
 type TerminalConfig struct {
 	Height float64
+	// Whether to enable the kitty keyboard protocol.
+	KittyKeyboard bool
+	// Files to record a session to, or to replay a session from.
+	Record string
+	Replay string
+	// Whether to replay the session on a virtual terminal instead of the
+	// controlling terminal.
+	Headless bool
+	// Colors supported by the terminal.
+	ColorDepth ColorDepth
 }
 
+// OpenTerm opens a terminal on the controlling terminal of the process, or on
+// a virtual terminal if headless.
 func OpenTerm(config *TerminalConfig) (*Terminal, error) {
-	inFd, err := sys.Open("/dev/tty", sys.O_RDONLY, 0)
+	backend, err := openBackend(config)
 	if err != nil {
 		return nil, err
 	}
-	outFd, err := sys.Open("/dev/tty", sys.O_WRONLY, 0)
+	if config.Replay != "" {
+		f, err := os.Open(config.Replay)
+		if err != nil {
+			backend.Close()
+			return nil, err
+		}
+		defer f.Close()
+		if backend, err = Replay(f, backend, !config.Headless); err != nil {
+			return nil, err
+		}
+	}
+	return NewTerm(config, backend)
+}
+
+func openBackend(config *TerminalConfig) (Backend, error) {
+	if config.Headless {
+		if config.Replay == "" {
+			return nil, fmt.Errorf("A session to replay is required without a terminal")
+		}
+		// Resized to the size of the session when replaying.
+		return NewVirtualBackend(24, 80), nil
+	}
+	var record io.WriteCloser
+	if config.Record != "" {
+		f, err := os.Create(config.Record)
+		if err != nil {
+			return nil, err
+		}
+		record = f
+	}
+	return OpenTTY(record)
+}
+
+// NewTerm opens a terminal on a backend.
+func NewTerm(config *TerminalConfig, backend Backend) (*Terminal, error) {
 	term := Terminal{
-		config:     config,
-		in:         os.NewFile(uintptr(inFd), "/dev/tty"),
-		out:        os.NewFile(uintptr(outFd), "/dev/tty"),
-		currentRow: 1,
+		config:  config,
+		backend: backend,
+		tasks:   make(chan func() error),
+		done:    make(chan struct{}),
 	}
 
 	return &term, term.initTerm()
 }
 
+func (t *Terminal) write(s string) {
+	io.WriteString(t.backend, s)
+}
+
 func (t *Terminal) initTerm() error {
-	state, err := terminal.MakeRaw(int(t.out.Fd()))
-	if err != nil {
+	if err := t.backend.MakeRaw(); err != nil {
 		return err
 	}
-	t.originalState = *state
 
 	if t.config.Height == 1.0 {
-		t.out.WriteString(enableAltBuf)
-		t.out.WriteString(cursorPosition(1, 1))
+		t.write(enableAltBuf)
+		t.write(cursorPosition(1, 1))
 	} else {
-		t.out.WriteString(deviceStatusReport)
-		_, col, err := readReport(t.in)
+		_, col, err := t.backend.CursorPosition()
 		if err == nil && col > 1 {
-			t.out.WriteString("\n")
+			t.write("\n")
 			t.insertedNewline = true
 		}
 	}
 
-	t.out.WriteString(disableWrap)
-	t.out.WriteString(hideCursor)
+	t.write(disableWrap)
+	t.write(hideCursor)
+	t.write(enableBracketedPaste)
+	if t.config.KittyKeyboard {
+		t.write(enableKittyKeyboard)
+	}
 	return nil
 }
 
 func (t *Terminal) revertTerm() {
 	if t.config.Height == 1.0 {
-		t.out.WriteString(enableAltBuf)
-		t.out.WriteString(disableAltBuf)
+		t.write(enableAltBuf)
+		t.write(disableAltBuf)
 	} else if t.insertedNewline {
-		t.out.WriteString(cursorUp())
+		t.write(cursorUp())
+	}
+	t.previousRender = nil
+	if t.config.KittyKeyboard {
+		t.write(disableKittyKeyboard)
 	}
-	t.previousRender = map[string]bool{}
-	t.out.WriteString(enableWrap)
-	t.out.WriteString(showCursor)
-	terminal.Restore(int(t.out.Fd()), &t.originalState)
+	t.write(disableBracketedPaste)
+	t.write(enableWrap)
+	t.write(showCursor)
+	t.backend.Restore()
 }
 
 func (t *Terminal) Close() {
-	t.out.WriteString(eraseDisplayEnd)
+	t.write(eraseDisplayEnd)
 	t.revertTerm()
-	t.in.Close()
-	t.out.Close()
-}
-
-func (t *Terminal) position(row int, col int) string {
-	out := &strings.Builder{}
-	out.WriteString(cursorBack(t.cols))
-	vertical := row - t.currentRow
-	if vertical > 0 {
-		out.WriteString(cursorDown(vertical))
-	} else if vertical < 0 {
-		out.WriteString(cursorUp(-vertical))
-	}
-	out.WriteString(cursorForward(col - 1))
-	t.currentRow = row
-	return out.String()
-}
-
-func (t *Terminal) border(p Position) string {
-	if p.Rows < 2 || p.Cols < 2 {
-		return ""
-	}
-	out := &strings.Builder{}
-	out.WriteString(t.position(p.Top, p.Left))
-	out.WriteString("┌" + strings.Repeat("─", p.Cols-2) + "┐")
-	for i := 1; i < p.Rows-1; i++ {
-		out.WriteString(t.position(p.Top+i, p.Left+p.Cols-1))
-		out.WriteString("│")
-	}
-	for i := 1; i < p.Rows-1; i++ {
-		out.WriteString(t.position(p.Top+i, p.Left))
-		out.WriteString("│")
-	}
-	out.WriteString(t.position(p.Top+p.Rows-1, p.Left))
-	out.WriteString("└" + strings.Repeat("─", p.Cols-2) + "┘")
-	out.WriteString(t.position(1, 1))
-	return out.String()
+	t.backend.Close()
 }
 
 func (t *Terminal) render(views []View) {
-	out := &strings.Builder{}
-	newRender := map[string]bool{}
+	screen := newScreen(t.rows, t.cols)
 	for _, view := range views {
 		if !view.ShouldRender() {
 			continue
@@ -136,43 +155,26 @@ func (t *Terminal) render(views []View) {
 
 		p := view.Position(t.rows, t.cols)
 		if view.HasBorder() {
-			s := t.border(p)
-			if _, ok := t.previousRender[s]; !ok {
-				out.WriteString(s)
-			}
-			newRender[s] = true
+			screen.setBorder(p)
 			p = p.Shrink(1)
 		}
 
 		lines := view.Render(p)
 		for row := 0; row < p.Rows; row++ {
-			lineRender := &strings.Builder{}
-			lineRender.WriteString(t.position(p.Top+row, p.Left))
+			text := ""
 			if row < len(lines) {
-				lineRender.WriteString(lines[row].Text())
-				if p.Cols > lines[row].Length() {
-					lineRender.WriteString(strings.Repeat(" ", p.Cols-lines[row].Length()))
-				}
-			} else {
-				lineRender.WriteString(strings.Repeat(" ", p.Cols))
-			}
-			if p.Top+row < t.rows {
-				lineRender.WriteString("\n")
-				t.currentRow += 1
-			}
-			lineRender.WriteString(t.position(1, 1))
-			if _, ok := t.previousRender[lineRender.String()]; !ok {
-				out.WriteString(lineRender.String())
+				text = lines[row].Text()
 			}
-			newRender[lineRender.String()] = true
+			screen.setText(p.Top+row, p.Left, p.Cols, text)
 		}
 	}
-	t.out.WriteString(out.String())
-	t.previousRender = newRender
+	screen.downgradeColors(t.config.ColorDepth)
+	t.write(screen.diff(t.previousRender))
+	t.previousRender = screen
 }
 
 func (t *Terminal) fetchWinSize() error {
-	width, height, err := terminal.GetSize(int(t.out.Fd()))
+	height, width, err := t.backend.Size()
 	if err != nil {
 		return err
 	}
@@ -191,12 +193,9 @@ func (t *Terminal) StartLoop(bindings map[string][]string, views []View) (err er
 	intSigs := make(chan os.Signal, 1)
 	signal.Notify(intSigs, sys.SIGINT, sys.SIGTERM)
 
-	winChSig := make(chan os.Signal, 1)
-	signal.Notify(winChSig, sys.SIGWINCH)
-
-	events := make(chan Event)
+	events := make(chan []Event)
 	nextEvents := make(chan bool)
-	go readEvents(t.in, events, nextEvents)
+	go t.backend.ReadEvents(events, nextEvents)
 
 	err = t.fetchWinSize()
 	if err != nil {
@@ -204,43 +203,56 @@ func (t *Terminal) StartLoop(bindings map[string][]string, views []View) (err er
 	}
 	t.render(views)
 
+	defer close(t.done)
+
 	t.loop = true
 	for {
 		select {
 		case <-intSigs:
 			zap.L().Debug("Received interrupt.")
 			t.loop = false
-		case <-winChSig:
+		case <-t.backend.Resized():
 			zap.L().Debug("Received window change.")
 			t.fetchWinSize()
 			t.render(views)
 			zap.L().Debug("Rerendered.")
-		case event := <-events:
-			zap.L().Sugar().Debug("Event: ", event)
-			cmdKeys, ok := bindings[event.HashKey()]
-			zap.L().Sugar().Debug("Cmds: ", cmdKeys)
+		case task := <-t.tasks:
+			if err := task(); err != nil {
+				return err
+			}
+			t.render(views)
+		case batch, ok := <-events:
 			if !ok {
-				continue
+				zap.L().Debug("Input ended.")
+				t.loop = false
+				break
 			}
-			for _, cmdKey := range cmdKeys {
-				if cmd, ok := t.getCommands()[cmdKey]; ok {
-					err := cmd(t)
-					if err != nil {
-						return err
-					}
-				} else {
-					for _, view := range views {
-						if cmd, ok := view.GetCommands()[cmdKey]; ok {
-							err := cmd(t)
-							if err != nil {
-								return err
-							}
-							break
-						}
-					}
+			// Keys queued while busy, such as repeated keys, are rendered
+			// once.
+			handled := false
+			for _, event := range batch {
+				zap.L().Sugar().Debug("Event: ", event)
+				cmdKeys, ok := bindings[event.HashKey()]
+				zap.L().Sugar().Debug("Cmds: ", cmdKeys)
+				if !ok {
+					continue
+				}
+				// Commands bound to paste receive the pasted text.
+				args := []interface{}{}
+				if event.Symbol == Paste {
+					args = append(args, event.Text)
+				}
+				if err := t.runCommands(cmdKeys, views, args...); err != nil {
+					return err
+				}
+				handled = true
+				if !t.loop {
+					break
 				}
 			}
-			t.render(views)
+			if handled {
+				t.render(views)
+			}
 		case nextEvents <- true:
 		}
 		if !t.loop {
@@ -250,6 +262,37 @@ func (t *Terminal) StartLoop(bindings map[string][]string, views []View) (err er
 	return err
 }
 
+func (t *Terminal) runCommands(cmdKeys []string, views []View, args ...interface{}) error {
+	for _, cmdKey := range cmdKeys {
+		var err error
+		if cmd, ok := t.getCommands()[cmdKey]; ok {
+			err = cmd(t, args...)
+		} else {
+			for _, view := range views {
+				if cmd, ok := view.GetCommands()[cmdKey]; ok {
+					err = cmd(t, args...)
+					break
+				}
+			}
+		}
+		if errors.Is(err, ErrSkipCommands) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Post runs the task on the event loop and rerenders afterwards. It can be
+// called from any goroutine, and drops the task if the loop has stopped.
+func (t *Terminal) Post(task func() error) {
+	select {
+	case t.tasks <- task:
+	case <-t.done:
+	}
+}
+
 func (t *Terminal) getCommands() map[string]Command {
 	return map[string]Command{
 		"quit": func(_ TerminalHelper, args ...interface{}) error {
@@ -268,9 +311,7 @@ func (t *Terminal) ExecuteInTerminal(cmd string) (string, error) {
 	defer tempF.Close()
 
 	fzf := exec.Command("bash", "-c", cmd+" > "+tempF.Name())
-	fzf.Stdin = t.in
-	fzf.Stdout = t.out
-	fzf.Stderr = t.out
+	t.backend.Attach(fzf)
 	t.revertTerm()
 	defer t.initTerm()
 	err = fzf.Run()
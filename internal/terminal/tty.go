@@ -0,0 +1,142 @@
+package terminal
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"golang.org/x/crypto/ssh/terminal"
+	sys "golang.org/x/sys/unix"
+)
+
+// Backend is the device on which a terminal is shown, and from which its
+// input is read.
+type Backend interface {
+	io.Writer
+	// MakeRaw puts the device in raw mode, and Restore reverts it.
+	MakeRaw() error
+	Restore() error
+	Size() (rows int, cols int, err error)
+	// Resized receives a value when the size of the device changes.
+	Resized() <-chan struct{}
+	// CursorPosition returns the row and column of the cursor, starting
+	// from 1.
+	CursorPosition() (int, int, error)
+	// ReadEvents sends batches of input events to out until the device is
+	// closed. After each batch, it waits to receive from next before reading
+	// more input.
+	ReadEvents(out chan []Event, next chan bool)
+	// Attach connects the standard streams of a command to the device.
+	Attach(cmd *exec.Cmd)
+	Close() error
+}
+
+// ttyBackend is the controlling terminal of the process.
+type ttyBackend struct {
+	in            *os.File
+	out           *os.File
+	originalState *terminal.State
+	signals       chan os.Signal
+	resized       chan struct{}
+	recorder      *sessionRecorder
+	record        io.WriteCloser
+}
+
+// OpenTTY opens the controlling terminal, even if the standard streams are
+// redirected. If record is not nil, the input and size of the terminal are
+// recorded to it as a session, which can be replayed with Replay.
+func OpenTTY(record io.WriteCloser) (Backend, error) {
+	inFd, err := sys.Open("/dev/tty", sys.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	outFd, err := sys.Open("/dev/tty", sys.O_WRONLY, 0)
+	if err != nil {
+		sys.Close(inFd)
+		return nil, err
+	}
+	b := &ttyBackend{
+		in:      os.NewFile(uintptr(inFd), "/dev/tty"),
+		out:     os.NewFile(uintptr(outFd), "/dev/tty"),
+		signals: make(chan os.Signal, 1),
+		resized: make(chan struct{}, 1),
+	}
+	if record != nil {
+		b.record = record
+		b.recorder = newSessionRecorder(record)
+	}
+	signal.Notify(b.signals, sys.SIGWINCH)
+	go func() {
+		for range b.signals {
+			select {
+			case b.resized <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return b, nil
+}
+
+func (b *ttyBackend) Write(p []byte) (int, error) {
+	return b.out.Write(p)
+}
+
+func (b *ttyBackend) MakeRaw() error {
+	state, err := terminal.MakeRaw(int(b.out.Fd()))
+	if err != nil {
+		return err
+	}
+	b.originalState = state
+	return nil
+}
+
+func (b *ttyBackend) Restore() error {
+	if b.originalState == nil {
+		return nil
+	}
+	return terminal.Restore(int(b.out.Fd()), b.originalState)
+}
+
+func (b *ttyBackend) Size() (int, int, error) {
+	cols, rows, err := terminal.GetSize(int(b.out.Fd()))
+	if err == nil && b.recorder != nil {
+		b.recorder.size(rows, cols)
+	}
+	return rows, cols, err
+}
+
+func (b *ttyBackend) Resized() <-chan struct{} {
+	return b.resized
+}
+
+func (b *ttyBackend) CursorPosition() (int, int, error) {
+	if _, err := b.out.WriteString(deviceStatusReport); err != nil {
+		return 0, 0, err
+	}
+	return readReport(b.in)
+}
+
+func (b *ttyBackend) ReadEvents(out chan []Event, next chan bool) {
+	if b.recorder != nil {
+		readEvents(&recordingReader{f: b.in, recorder: b.recorder}, out, next)
+	} else {
+		readEvents(b.in, out, next)
+	}
+}
+
+func (b *ttyBackend) Attach(cmd *exec.Cmd) {
+	cmd.Stdin = b.in
+	cmd.Stdout = b.out
+	cmd.Stderr = b.out
+}
+
+func (b *ttyBackend) Close() error {
+	signal.Stop(b.signals)
+	close(b.signals)
+	if b.record != nil {
+		b.record.Close()
+	}
+	b.in.Close()
+	return b.out.Close()
+}
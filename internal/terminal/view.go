@@ -1,5 +1,7 @@
 package terminal
 
+import "errors"
+
 type View interface {
 	Position(int, int) Position
 	HasBorder() bool
@@ -10,8 +12,13 @@ type View interface {
 
 type Command func(helper TerminalHelper, args ...interface{}) error
 
+// ErrSkipCommands can be returned by a command to skip the remaining commands
+// bound to the same key.
+var ErrSkipCommands = errors.New("skip remaining commands")
+
 type TerminalHelper interface {
 	ExecuteInTerminal(string) (string, error)
+	Post(func() error)
 }
 
 type Position struct {
@@ -0,0 +1,283 @@
+package terminal
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// VirtualBackend is a terminal in memory, for tests. Output is interpreted
+// into a grid of cells, and input events are sent by the test.
+type VirtualBackend struct {
+	mu     sync.Mutex
+	screen *screen
+	row    int
+	col    int
+	style  style
+	// Output which ends within an escape code or rune.
+	pending []byte
+
+	input   chan []Event
+	handled chan struct{}
+	resized chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func NewVirtualBackend(rows int, cols int) *VirtualBackend {
+	return &VirtualBackend{
+		screen:  newScreen(rows, cols),
+		row:     1,
+		col:     1,
+		input:   make(chan []Event),
+		handled: make(chan struct{}),
+		resized: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Send sends events to the terminal as one batch, and waits until they are
+// handled and rendered, or until the backend is closed.
+func (b *VirtualBackend) Send(events ...Event) {
+	select {
+	case b.input <- events:
+	case <-b.closed:
+		return
+	}
+	select {
+	case <-b.handled:
+	case <-b.closed:
+	}
+}
+
+// Resize changes the size of the terminal, clearing its contents, and waits
+// until the terminal is rendered again.
+func (b *VirtualBackend) Resize(rows int, cols int) {
+	b.resize(rows, cols)
+	select {
+	case b.resized <- struct{}{}:
+	case <-b.closed:
+		return
+	}
+	b.Send()
+}
+
+func (b *VirtualBackend) resize(rows int, cols int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.screen = newScreen(rows, cols)
+	b.row, b.col = 1, 1
+}
+
+// Screen returns the text shown in the terminal, with trailing blanks removed
+// from each row.
+func (b *VirtualBackend) Screen() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.screen.String()
+}
+
+// Write interprets output containing text, line breaks, cursor movements,
+// erasures and SGR escape codes. Other escape codes are ignored.
+func (b *VirtualBackend) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := string(append(b.pending, p...))
+	b.pending = nil
+	for len(s) > 0 {
+		switch {
+		case s[0] == '\x1b':
+			n := csiLength(s)
+			if n == 0 {
+				b.pending = []byte(s)
+				return len(p), nil
+			}
+			b.escape(s[:n])
+			s = s[n:]
+			continue
+		case s[0] == '\r':
+			b.col = 1
+		case s[0] == '\n':
+			b.lineFeed()
+		case !utf8.FullRuneInString(s):
+			b.pending = []byte(s)
+			return len(p), nil
+		default:
+			r, size := utf8.DecodeRuneInString(s)
+			b.put(r)
+			s = s[size:]
+			continue
+		}
+		s = s[1:]
+	}
+	return len(p), nil
+}
+
+// csiLength returns the length of the escape code at the start of the output,
+// or 0 if it is incomplete.
+func csiLength(s string) int {
+	if len(s) < 2 {
+		return 0
+	}
+	if s[1] != '[' {
+		return 2
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] >= 0x40 && s[i] <= 0x7e {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (b *VirtualBackend) put(r rune) {
+	w := runeWidth(r)
+	if w == 0 {
+		return
+	}
+	b.screen.set(b.row, b.col, cell{r: r, width: w, style: b.style})
+	// Wrapping is disabled, so the cursor stays at the last column.
+	b.col += w
+	if b.col > b.screen.cols {
+		b.col = b.screen.cols
+	}
+}
+
+func (b *VirtualBackend) lineFeed() {
+	if b.row < b.screen.rows {
+		b.row++
+		return
+	}
+	// Scroll up.
+	b.screen.cells = append(b.screen.cells[1:], newScreen(1, b.screen.cols).cells[0])
+}
+
+func (b *VirtualBackend) escape(code string) {
+	if len(code) < 3 || code[1] != '[' {
+		return
+	}
+	final := code[len(code)-1]
+	params := code[2 : len(code)-1]
+	if final == 'm' {
+		b.style.apply(params)
+		return
+	}
+	if strings.ContainsAny(params, "?<>=") {
+		// Private modes.
+		return
+	}
+	values := parseParams(params)
+	n := 1
+	if len(values) > 0 && values[0] > 0 {
+		n = values[0]
+	}
+	switch final {
+	case 'A':
+		b.row -= n
+	case 'B':
+		b.row += n
+	case 'C':
+		b.col += n
+	case 'D':
+		b.col -= n
+	case 'H':
+		b.row, b.col = n, 1
+		if len(values) > 1 && values[1] > 0 {
+			b.col = values[1]
+		}
+	case 'J':
+		from := b.row
+		if len(values) > 0 && values[0] == 2 {
+			from = 1
+		} else {
+			b.eraseLine(b.col)
+			from++
+		}
+		for row := from; row <= b.screen.rows; row++ {
+			b.screen.cells[row-1] = newScreen(1, b.screen.cols).cells[0]
+		}
+	case 'K':
+		b.eraseLine(b.col)
+	}
+	b.row = clamp(b.row, 1, b.screen.rows)
+	b.col = clamp(b.col, 1, b.screen.cols)
+}
+
+func (b *VirtualBackend) eraseLine(from int) {
+	for col := from; col <= b.screen.cols; col++ {
+		b.screen.set(b.row, col, blankCell)
+	}
+}
+
+func clamp(value int, min int, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+func (b *VirtualBackend) MakeRaw() error {
+	return nil
+}
+
+func (b *VirtualBackend) Restore() error {
+	return nil
+}
+
+func (b *VirtualBackend) Size() (int, int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.screen.rows, b.screen.cols, nil
+}
+
+func (b *VirtualBackend) Resized() <-chan struct{} {
+	return b.resized
+}
+
+func (b *VirtualBackend) CursorPosition() (int, int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.row, b.col, nil
+}
+
+func (b *VirtualBackend) ReadEvents(out chan []Event, next chan bool) {
+	for {
+		var events []Event
+		select {
+		case events = <-b.input:
+		case <-b.closed:
+			return
+		}
+		select {
+		case out <- events:
+		case <-b.closed:
+			return
+		}
+		select {
+		case <-next:
+		case <-b.closed:
+			return
+		}
+		select {
+		case b.handled <- struct{}{}:
+		case <-b.closed:
+			return
+		}
+	}
+}
+
+// Attach runs commands without input, discarding their output.
+func (b *VirtualBackend) Attach(cmd *exec.Cmd) {
+	cmd.Stdin = nil
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+}
+
+func (b *VirtualBackend) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
@@ -0,0 +1,130 @@
+package views
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+const (
+	// Number of bytes inspected to detect binary files.
+	binaryDetectionSize = 8 << 10
+	// Maximum number of bytes shown in the hex dump of a binary file.
+	maxHexdumpSize = 64 << 10
+)
+
+type binaryPreview struct {
+	data     []byte
+	size     int64
+	mimeType string
+}
+
+// isBinary returns whether the head of a file looks like binary content,
+// i.e. whether it contains NUL bytes or invalid UTF-8. A rune cut off at the
+// end of a truncated head is not considered invalid.
+func isBinary(head []byte, truncated bool) bool {
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	if utf8.Valid(head) {
+		return false
+	}
+	if truncated {
+		for i := 1; i < utf8.UTFMax && i <= len(head); i++ {
+			if utf8.Valid(head[:len(head)-i]) {
+				return utf8.FullRune(head[len(head)-i:])
+			}
+		}
+	}
+	return true
+}
+
+// readBinaryPreview returns the preview of a binary file, or nil if the file
+// is not binary.
+func readBinaryPreview(file *previewFile) (*binaryPreview, error) {
+	if err := file.readHead(maxHexdumpSize); err != nil {
+		return nil, err
+	}
+	data := file.head
+	if len(data) > maxHexdumpSize {
+		data = data[:maxHexdumpSize]
+	}
+	head := data
+	if len(head) > binaryDetectionSize {
+		head = head[:binaryDetectionSize]
+	}
+	if !isBinary(head, int64(len(head)) < file.size) {
+		return nil, nil
+	}
+	return &binaryPreview{
+		data:     append([]byte(nil), data...),
+		size:     file.size,
+		mimeType: http.DetectContentType(head),
+	}, nil
+}
+
+// bytesPerRow returns the number of bytes per hex dump row fitting in the
+// given width, as a multiple of 4 if possible.
+func bytesPerRow(cols int) int {
+	// Offset and separators take up 13 columns, each byte 4 columns.
+	n := (cols - 13) / 4
+	if n >= 4 {
+		n -= n % 4
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (b *binaryPreview) noLines(cols int) int {
+	n := bytesPerRow(cols)
+	lines := 1 + (len(b.data)+n-1)/n
+	if int64(len(b.data)) < b.size {
+		lines++
+	}
+	return lines
+}
+
+func (b *binaryPreview) line(i int, cols int) string {
+	if i == 0 {
+		return fmt.Sprintf("Binary file, %s, %s", humanizeSize(b.size), b.mimeType)
+	}
+	n := bytesPerRow(cols)
+	offset := (i - 1) * n
+	if offset >= len(b.data) {
+		return fmt.Sprintf("... %d more bytes", b.size-int64(len(b.data)))
+	}
+	end := offset + n
+	if end > len(b.data) {
+		end = len(b.data)
+	}
+	hex := &strings.Builder{}
+	ascii := &strings.Builder{}
+	for j := offset; j < offset+n; j++ {
+		if j >= end {
+			hex.WriteString("   ")
+			continue
+		}
+		c := b.data[j]
+		fmt.Fprintf(hex, "%02x ", c)
+		if c >= 0x20 && c < 0x7f {
+			ascii.WriteByte(c)
+		} else {
+			ascii.WriteByte('.')
+		}
+	}
+	return fmt.Sprintf("%08x  %s |%s|", offset, hex.String(), ascii.String())
+}
+
+func (v *previewView) renderBinaryLine(line term.Line, i int, cols int) {
+	if i == 0 {
+		line.Append(v.lastBinary.line(i, cols), v.config.Graphics["preview:header"])
+	} else {
+		line.Append(v.lastBinary.line(i, cols), nil)
+	}
+}
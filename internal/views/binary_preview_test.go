@@ -0,0 +1,109 @@
+package views
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wvanlint/twf/internal/config"
+	"github.com/wvanlint/twf/internal/filetree"
+	"github.com/wvanlint/twf/internal/state"
+)
+
+func TestIsBinary(t *testing.T) {
+	assert.False(t, isBinary([]byte("hello\nworld"), false))
+	assert.False(t, isBinary([]byte("héllo"), false))
+	assert.True(t, isBinary([]byte("hel\x00lo"), false))
+	assert.True(t, isBinary([]byte("hel\xfflo"), false))
+	// A rune cut off at the end of a truncated head.
+	assert.False(t, isBinary([]byte("h\xc3"), true))
+	assert.True(t, isBinary([]byte("h\xc3"), false))
+}
+
+func TestBytesPerRow(t *testing.T) {
+	assert.Equal(t, 16, bytesPerRow(77))
+	assert.Equal(t, 12, bytesPerRow(76))
+	assert.Equal(t, 1, bytesPerRow(10))
+}
+
+func TestBinaryPreviewLines(t *testing.T) {
+	b := &binaryPreview{
+		data:     []byte("\x00\x01abcdefghij"),
+		size:     100,
+		mimeType: "application/octet-stream",
+	}
+	assert.Equal(t, 5, b.noLines(29))
+	assert.Equal(t, "Binary file, 100B, application/octet-stream", b.line(0, 29))
+	assert.Equal(t, "00000000  00 01 61 62  |..ab|", b.line(1, 29))
+	assert.Equal(t, "00000008  67 68 69 6a  |ghij|", b.line(3, 29))
+	assert.Equal(t, "... 88 more bytes", b.line(4, 29))
+	assert.Equal(t, 6, b.noLines(28))
+	assert.Equal(t, "00000000  00 01 61  |..a|", b.line(1, 28))
+
+	for _, cols := range []int{28, 44, 76} {
+		for i := 1; i < b.noLines(cols)-1; i++ {
+			assert.LessOrEqual(t, len(b.line(i, cols)), cols, cols)
+		}
+	}
+
+	b.size = 11
+	b.data = b.data[:11]
+	assert.Equal(t, "00000008  67 68 69     |ghi|", b.line(3, 29))
+}
+
+func TestBinaryPreviewSkipsSpecialFiles(t *testing.T) {
+	fsys := filetree.NewMemFS()
+	fsys.AddFile("fifo", "\x00\x01")
+	fsys.SetMode("fifo", fs.ModeNamedPipe|0644)
+	tree, err := filetree.InitFileTreeFS(fsys, ".")
+	assert.Nil(t, err)
+	children, err := tree.Children(nil)
+	assert.Nil(t, err)
+
+	c := &config.TwfConfig{}
+	c.Preview.PreviewCommand = config.BuiltinPreviewCommand
+	v := &previewView{config: c, state: &state.State{Root: tree, Cursor: children[0]}}
+	v.update()
+	assert.Nil(t, v.lastBinary)
+	assert.Equal(t, []string{"Named pipe"}, v.lastPreview)
+}
+
+// countingFS counts the files opened in a filesystem.
+type countingFS struct {
+	*filetree.MemFS
+	opened int
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.opened++
+	return c.MemFS.Open(name)
+}
+
+func TestPreviewReadsFileOnce(t *testing.T) {
+	fsys := &countingFS{MemFS: filetree.NewMemFS()}
+	fsys.AddFile("notes", "some notes")
+	fsys.AddFile("data", "\x00\x01")
+	tree, err := filetree.InitFileTreeFS(fsys, ".")
+	assert.Nil(t, err)
+	children, err := tree.Children(nil)
+	assert.Nil(t, err)
+
+	c := &config.TwfConfig{}
+	c.Preview.PreviewCommand = "cat {}"
+	v := &previewView{config: c, state: &state.State{Root: tree}}
+	for _, child := range children {
+		fsys.opened = 0
+		v.state.Cursor = child
+		v.update()
+		assert.Equal(t, 1, fsys.opened, child.Name())
+	}
+	assert.Equal(t, []string{"some notes"}, v.lastPreview)
+
+	c.Preview.PreviewCommand = config.BuiltinPreviewCommand
+	c.Preview.PreviewFallbackCommand = "tr a-z A-Z < {}"
+	v.lastPath = ""
+	fsys.opened = 0
+	v.update()
+	assert.Equal(t, 1, fsys.opened)
+	assert.Equal(t, []string{"SOME NOTES"}, v.lastPreview)
+}
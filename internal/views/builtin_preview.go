@@ -0,0 +1,76 @@
+package views
+
+import (
+	"os"
+	"strings"
+
+	"github.com/wvanlint/twf/internal/filetree"
+	"github.com/wvanlint/twf/internal/highlight"
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+// Maximum number of bytes of a file shown by the built-in preview.
+const maxBuiltinPreviewSize = 1 << 20
+
+// Maximum number of bytes of a file highlighted by the built-in preview, which
+// keeps highlighting within tens of milliseconds. The rest is shown as is.
+const maxHighlightSize = 64 << 10
+
+// builtinPreview highlights the file if its language is known. Otherwise the
+// fallback command is used, or the plain contents if there is none. The file
+// is opened unless it is given.
+func (v *previewView) builtinPreview(node *filetree.FileTree, file *previewFile) ([]string, error) {
+	if !node.TargetMode().IsRegular() {
+		// Reading pipes or devices could block indefinitely.
+		return []string{fileTypeName(node.TargetMode())}, nil
+	}
+	if file == nil {
+		var err error
+		if file, err = openPreviewFile(node); err != nil {
+			return nil, err
+		}
+		defer file.Close()
+	}
+	if err := file.readHead(maxBuiltinPreviewSize); err != nil {
+		return nil, err
+	}
+	content := string(file.head)
+
+	lang := highlight.Detect(node.Name(), content)
+	if lang != nil {
+		return lang.HighlightPrefix(content, maxHighlightSize, v.highlightStyles()), nil
+	}
+	if v.config.Preview.PreviewFallbackCommand != "" {
+		return runPreview(v.config.Preview.PreviewFallbackCommand, node, file)
+	}
+	return strings.Split(content, "\n"), nil
+}
+
+// fileTypeName describes the type of a file which isn't a regular file or a
+// directory.
+func fileTypeName(mode os.FileMode) string {
+	switch {
+	case mode&os.ModeNamedPipe != 0:
+		return "Named pipe"
+	case mode&os.ModeSocket != 0:
+		return "Socket"
+	case mode&os.ModeCharDevice != 0:
+		return "Character device"
+	case mode&os.ModeDevice != 0:
+		return "Block device"
+	case mode&os.ModeSymlink != 0:
+		return "Broken symbolic link"
+	default:
+		return "Special file"
+	}
+}
+
+func (v *previewView) highlightStyles() map[highlight.TokenKind]*term.Graphics {
+	styles := map[highlight.TokenKind]*term.Graphics{}
+	for _, kind := range highlight.TokenKinds() {
+		if g, ok := v.config.Graphics["preview:"+kind.String()]; ok {
+			styles[kind] = g
+		}
+	}
+	return styles
+}
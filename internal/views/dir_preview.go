@@ -0,0 +1,71 @@
+package views
+
+import (
+	"fmt"
+
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+// renderDirLine renders a line of the built-in directory preview. The first
+// line summarizes the directory, the others list its entries. Entries are
+// only counted for directories which have been read already, since reading
+// them here would block rendering.
+func (v *previewView) renderDirLine(line term.Line, i int) {
+	if i == 0 {
+		dirs, files := 0, 0
+		var size int64
+		for _, entry := range v.lastEntries {
+			if entry.IsDir() {
+				dirs++
+			} else {
+				files++
+				size += entry.Size()
+			}
+		}
+		line.Append(
+			fmt.Sprintf("%s, %s, %s", plural(dirs, "directory", "directories"), plural(files, "file", "files"), humanizeSize(size)),
+			v.config.Graphics["preview:header"],
+		)
+		return
+	}
+
+	entry := v.lastEntries[i-1]
+	if entry.IsDir() {
+		count := "?"
+		if entry.Loaded() {
+			if children, err := entry.Children(nil); err == nil {
+				count = fmt.Sprint(len(children))
+			}
+		}
+		line.Append(fmt.Sprintf("%6s  ", count), nil)
+		line.Append(entry.Name()+"/", v.config.Graphics["tree:dir"])
+	} else {
+		line.Append(fmt.Sprintf("%6s  ", humanizeSize(entry.Size())), nil)
+		line.Append(entry.Name(), nil)
+	}
+}
+
+// plural returns the count followed by the singular or plural noun.
+func plural(count int, singular string, plural string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %s", count, singular)
+	}
+	return fmt.Sprintf("%d %s", count, plural)
+}
+
+func humanizeSize(size int64) string {
+	const units = "KMGTPE"
+	if size < 1024 {
+		return fmt.Sprint(size, "B")
+	}
+	value := float64(size)
+	unit := -1
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if value < 10 {
+		return fmt.Sprintf("%.1f%c", value, units[unit])
+	}
+	return fmt.Sprintf("%.0f%c", value, units[unit])
+}
@@ -0,0 +1,22 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHumanizeSize(t *testing.T) {
+	assert.Equal(t, "0B", humanizeSize(0))
+	assert.Equal(t, "1023B", humanizeSize(1023))
+	assert.Equal(t, "1.0K", humanizeSize(1024))
+	assert.Equal(t, "1.5K", humanizeSize(1536))
+	assert.Equal(t, "10K", humanizeSize(10*1024))
+	assert.Equal(t, "3.0G", humanizeSize(3*1024*1024*1024))
+}
+
+func TestPlural(t *testing.T) {
+	assert.Equal(t, "0 files", plural(0, "file", "files"))
+	assert.Equal(t, "1 file", plural(1, "file", "files"))
+	assert.Equal(t, "2 directories", plural(2, "directory", "directories"))
+}
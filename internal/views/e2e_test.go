@@ -0,0 +1,144 @@
+package views
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wvanlint/twf/internal/config"
+	"github.com/wvanlint/twf/internal/filetree"
+	"github.com/wvanlint/twf/internal/state"
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+var update = flag.Bool("update", false, "Update the golden files of end-to-end tests.")
+
+// e2e runs twf on a virtual terminal.
+type e2e struct {
+	t       *testing.T
+	backend *term.VirtualBackend
+	state   *state.State
+	done    chan error
+}
+
+func startE2E(t *testing.T, fsys filetree.FS, bindings string, rows int, cols int) *e2e {
+	tree, err := filetree.InitFileTreeFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &state.State{
+		Root:      tree,
+		Cursor:    tree,
+		Loader:    filetree.NewLoader(1),
+		DiskUsage: filetree.NewDiskUsage(),
+	}
+	if err := s.AutoExpand(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	c := &config.TwfConfig{}
+	c.Terminal.Height = 1
+	c.Preview.Enabled = true
+	c.Preview.PreviewCommand = config.BuiltinPreviewCommand
+	c.Preview.Window = config.PreviewWindow{Position: config.PreviewRight, Size: 50, Percent: true, Border: true}
+	c.Graphics = config.NewGraphicsMapping()
+	c.Keybindings = config.Keybindings{}
+	if err := c.Keybindings.Set(bindings); err != nil {
+		t.Fatal(err)
+	}
+	layout := NewLayout(c)
+	views := []term.View{
+		NewTreeView(c, s, layout),
+		NewPreviewView(c, s, layout),
+		NewStatusView(c, s),
+	}
+
+	backend := term.NewVirtualBackend(rows, cols)
+	terminal, err := term.NewTerm(&c.Terminal, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Post = terminal.Post
+	e := &e2e{t: t, backend: backend, state: s, done: make(chan error, 1)}
+	go func() {
+		err := terminal.StartLoop(c.Keybindings, views)
+		terminal.Close()
+		e.done <- err
+	}()
+	// Wait for the first render.
+	backend.Send()
+	return e
+}
+
+func (e *e2e) keys(keys string) {
+	for _, r := range keys {
+		e.backend.Send(term.Event{Symbol: term.Rune, Value: r})
+	}
+}
+
+// assertScreen compares the screen with a golden file in testdata, which is
+// written instead with -update.
+func (e *e2e) assertScreen(name string) {
+	e.t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	screen := e.backend.Screen() + "\n"
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(screen), 0644); err != nil {
+			e.t.Fatal(err)
+		}
+		return
+	}
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	assert.Equal(e.t, string(golden), screen)
+}
+
+// wait waits until twf exits.
+func (e *e2e) wait() {
+	e.t.Helper()
+	if err := <-e.done; err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+func testFS() *filetree.MemFS {
+	fsys := filetree.NewMemFS()
+	fsys.AddFile("cmd/main.go", "package main\n\nfunc main() {\n}\n")
+	fsys.AddFile("docs/guide.md", "# Guide\n")
+	fsys.AddFile("README", "twf\n")
+	fsys.AddFile("wide 😊", "")
+	return fsys
+}
+
+func TestE2EBrowse(t *testing.T) {
+	e := startE2E(t, testFS(), "j::tree:next,k::tree:prev,l::tree:open,h::tree:close,q::quit,enter::tree:selectPath;quit", 10, 50)
+	e.assertScreen("browse_start")
+
+	e.keys("jl")
+	e.assertScreen("browse_open")
+
+	e.keys("jj")
+	e.assertScreen("browse_preview")
+
+	e.backend.Resize(8, 40)
+	e.assertScreen("browse_resized")
+
+	e.backend.Send(term.Event{Symbol: term.Enter})
+	e.wait()
+	assert.Equal(t, 1, len(e.state.Selection))
+	assert.Equal(t, "/docs", e.state.Selection[0].AbsPath)
+}
+
+func TestE2EPaste(t *testing.T) {
+	e := startE2E(t, testFS(), "paste::tree:locate,q::quit", 8, 40)
+	e.backend.Send(term.Event{Symbol: term.Paste, Text: "cmd/main.go:3\n"})
+	e.assertScreen("paste")
+
+	e.keys("q")
+	e.wait()
+	assert.Equal(t, "main.go", e.state.Cursor.Name())
+	assert.Equal(t, 3, e.state.Line)
+}
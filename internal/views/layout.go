@@ -0,0 +1,86 @@
+package views
+
+import (
+	"github.com/wvanlint/twf/internal/config"
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+// Layout divides the terminal between the tree and the preview, so that the
+// views agree on each other's geometry. The last row is left to the status
+// view.
+type Layout struct {
+	window config.PreviewWindow
+}
+
+func NewLayout(config *config.TwfConfig) *Layout {
+	window := config.Preview.Window
+	window.Hidden = window.Hidden || !config.Preview.Enabled
+	return &Layout{window: window}
+}
+
+func (l *Layout) PreviewVisible() bool {
+	return !l.window.Hidden
+}
+
+func (l *Layout) PreviewBorder() bool {
+	return l.window.Border
+}
+
+func (l *Layout) TogglePreview() {
+	l.window.Hidden = !l.window.Hidden
+}
+
+func (l *Layout) CyclePreviewPosition() {
+	l.window.Position = l.window.Position.Next()
+}
+
+func (l *Layout) Tree(totalRows int, totalCols int) term.Position {
+	tree, _ := l.compute(totalRows, totalCols)
+	return tree
+}
+
+func (l *Layout) Preview(totalRows int, totalCols int) term.Position {
+	_, preview := l.compute(totalRows, totalCols)
+	return preview
+}
+
+func (l *Layout) previewSize(available int) int {
+	size := l.window.Size
+	if l.window.Percent {
+		size = available * size / 100
+	}
+	if size > available-1 {
+		size = available - 1
+	}
+	if size < 0 {
+		size = 0
+	}
+	return size
+}
+
+func (l *Layout) compute(totalRows int, totalCols int) (term.Position, term.Position) {
+	tree := term.Position{Top: 1, Left: 1, Rows: totalRows - 1, Cols: totalCols}
+	if !l.PreviewVisible() {
+		return tree, term.Position{}
+	}
+	preview := tree
+	switch l.window.Position {
+	case config.PreviewRight:
+		preview.Cols = l.previewSize(tree.Cols)
+		tree.Cols -= preview.Cols
+		preview.Left = tree.Cols + 1
+	case config.PreviewLeft:
+		preview.Cols = l.previewSize(tree.Cols)
+		tree.Cols -= preview.Cols
+		tree.Left = preview.Cols + 1
+	case config.PreviewDown:
+		preview.Rows = l.previewSize(tree.Rows)
+		tree.Rows -= preview.Rows
+		preview.Top = tree.Rows + 1
+	case config.PreviewUp:
+		preview.Rows = l.previewSize(tree.Rows)
+		tree.Rows -= preview.Rows
+		tree.Top = preview.Rows + 1
+	}
+	return tree, preview
+}
@@ -0,0 +1,44 @@
+package views
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wvanlint/twf/internal/config"
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+func TestLayout(t *testing.T) {
+	c := &config.TwfConfig{}
+	c.Preview.Enabled = true
+	c.Preview.Window = config.PreviewWindow{Position: config.PreviewRight, Size: 50, Percent: true}
+	l := NewLayout(c)
+	assert.Equal(t, term.Position{Top: 1, Left: 1, Rows: 23, Cols: 41}, l.Tree(24, 81))
+	assert.Equal(t, term.Position{Top: 1, Left: 42, Rows: 23, Cols: 40}, l.Preview(24, 81))
+
+	l.CyclePreviewPosition()
+	assert.Equal(t, term.Position{Top: 1, Left: 1, Rows: 12, Cols: 80}, l.Tree(24, 80))
+	assert.Equal(t, term.Position{Top: 13, Left: 1, Rows: 11, Cols: 80}, l.Preview(24, 80))
+
+	l.CyclePreviewPosition()
+	assert.Equal(t, term.Position{Top: 1, Left: 41, Rows: 23, Cols: 40}, l.Tree(24, 80))
+	assert.Equal(t, term.Position{Top: 1, Left: 1, Rows: 23, Cols: 40}, l.Preview(24, 80))
+
+	l.CyclePreviewPosition()
+	assert.Equal(t, term.Position{Top: 12, Left: 1, Rows: 12, Cols: 80}, l.Tree(24, 80))
+	assert.Equal(t, term.Position{Top: 1, Left: 1, Rows: 11, Cols: 80}, l.Preview(24, 80))
+
+	l.TogglePreview()
+	assert.False(t, l.PreviewVisible())
+	assert.Equal(t, term.Position{Top: 1, Left: 1, Rows: 23, Cols: 80}, l.Tree(24, 80))
+}
+
+func TestLayoutPreviewDisabled(t *testing.T) {
+	c := &config.TwfConfig{}
+	c.Preview.Window = config.PreviewWindow{Position: config.PreviewDown, Size: 5}
+	l := NewLayout(c)
+	assert.False(t, l.PreviewVisible())
+	l.TogglePreview()
+	assert.Equal(t, term.Position{Top: 1, Left: 1, Rows: 18, Cols: 80}, l.Tree(24, 80))
+	assert.Equal(t, term.Position{Top: 19, Left: 1, Rows: 5, Cols: 80}, l.Preview(24, 80))
+}
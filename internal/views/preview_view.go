@@ -1,12 +1,16 @@
 package views
 
 import (
+	"bytes"
 	"fmt"
-	"math"
+	"io"
+	"io/fs"
+	"io/ioutil"
 	"os/exec"
 	"strings"
 
 	"github.com/wvanlint/twf/internal/config"
+	"github.com/wvanlint/twf/internal/filetree"
 	"github.com/wvanlint/twf/internal/state"
 	term "github.com/wvanlint/twf/internal/terminal"
 )
@@ -14,72 +18,303 @@ import (
 type previewView struct {
 	config      *config.TwfConfig
 	state       *state.State
+	layout      *Layout
 	lastPath    string
 	lastPreview []string
-	scroll      int
-	noLines     int
+	lastEntries []*filetree.FileTree
+	lastBinary  *binaryPreview
+	lastLoading bool
+	// Line located with the path of the preview, 0 if none.
+	lastLine int
+	// Whether to scroll to the located line on the next render.
+	scrollToLine bool
+	// Widest line of the text preview, in columns.
+	lastWidth int
+	scroll    int
+	hscroll   int
+	wrap      bool
+	// Rows of the text preview when wrapping, computed for wrappedCols.
+	wrapped     []wrappedRow
+	wrappedCols int
+	rows        int
+	cols        int
 }
 
-func NewPreviewView(config *config.TwfConfig, state *state.State) term.View {
+// wrappedRow is a part of a line of the text preview, starting at the given
+// column.
+type wrappedRow struct {
+	line   int
+	offset int
+}
+
+func NewPreviewView(config *config.TwfConfig, state *state.State, layout *Layout) term.View {
 	return &previewView{
 		config: config,
 		state:  state,
+		layout: layout,
 	}
 }
 
 func (v *previewView) Position(totalRows int, totalCols int) term.Position {
-	return term.Position{
-		Top:  1,
-		Left: int(math.Ceil(float64(totalCols)/2.0)) + 1,
-		Rows: totalRows - 1,
-		Cols: int(math.Floor(float64(totalCols) / 2.0)),
-	}
+	return v.layout.Preview(totalRows, totalCols)
 }
 
 func (v *previewView) HasBorder() bool {
-	return true
+	return v.layout.PreviewBorder()
 }
 
 func (v *previewView) ShouldRender() bool {
-	return v.config.Preview.Enabled && !v.state.Cursor.IsDir()
+	return v.layout.PreviewVisible()
 }
 
-func (v *previewView) Render(p term.Position) []term.Line {
-	if v.lastPath != v.state.Cursor.AbsPath {
-		v.lastPath = v.state.Cursor.AbsPath
+func (v *previewView) update() {
+	cursor := v.state.Cursor
+	line := 0
+	if cursor == v.state.Located {
+		line = v.state.Line
+	}
+	moved := v.lastPath != cursor.AbsPath
+	if !moved && v.lastLine == line && !v.lastLoading {
+		return
+	}
+	if moved || v.lastLine != line {
 		v.scroll = 0
+		v.hscroll = 0
+		v.lastLine = line
+		v.scrollToLine = line > 0
+	}
+	v.lastPath = cursor.AbsPath
+	v.lastLoading = cursor.Loading()
+	v.lastPreview = nil
+	v.lastEntries = nil
+	v.lastBinary = nil
+	v.lastWidth = 0
+	v.wrapped = nil
+
+	// The file is read once for all previews, as reading files in
+	// compressed archives means decompressing them from the start.
+	var file *previewFile
+	if !cursor.IsDir() && cursor.TargetMode().IsRegular() {
+		// Reading pipes or devices could block indefinitely.
+		if f, err := openPreviewFile(cursor); err == nil {
+			defer f.Close()
+			file = f
+			if binary, err := readBinaryPreview(file); err == nil && binary != nil {
+				v.lastBinary = binary
+				return
+			}
+		}
+	}
+
+	var lines []string
+	var err error
+	switch {
+	case cursor.IsDir() && (v.config.Preview.PreviewDirCommand == "" ||
+		cursor.Virtual() || cursor.IsArchive()):
+		// Directories in archives can't be passed to a command.
+		if moved && v.state.Post != nil {
+			// Read in the background, so that slow directories don't
+			// block rendering.
+			err = v.state.Loader.Load(cursor, v.state.Post)
+			v.lastLoading = cursor.Loading()
+		}
+		switch {
+		case err != nil:
+		case cursor.Loaded():
+			if v.lastEntries, err = cursor.OrderedChildren(); err == nil {
+				return
+			}
+		case cursor.Loading():
+			lines = []string{"Loading..."}
+		default:
+			lines = []string{}
+		}
+	case cursor.IsDir():
+		lines, err = runPreview(v.config.Preview.PreviewDirCommand, cursor, nil)
+	case v.config.Preview.PreviewCommand == config.BuiltinPreviewCommand:
+		lines, err = v.builtinPreview(cursor, file)
+	default:
+		lines, err = runPreview(v.config.Preview.PreviewCommand, cursor, file)
+	}
+	if err != nil {
+		lines = append(lines, strings.Split(err.Error(), "\n")...)
+	}
+	for i := range lines {
+		lines[i] = strings.ReplaceAll(lines[i], "\t", "    ")
+		if width := term.TextWidth(lines[i]); width > v.lastWidth {
+			v.lastWidth = width
+		}
+	}
+	v.lastPreview = lines
+}
 
-		preview, err := getPreview(v.config.Preview.PreviewCommand, v.state.Cursor.AbsPath)
-		if err != nil {
-			preview = err.Error()
+// wrappedRows splits the lines of the text preview into rows of the given
+// width.
+func (v *previewView) wrappedRows(cols int) []wrappedRow {
+	if v.wrapped != nil && v.wrappedCols == cols {
+		return v.wrapped
+	}
+	v.wrapped = []wrappedRow{}
+	v.wrappedCols = cols
+	for i, line := range v.lastPreview {
+		for _, offset := range term.WrapOffsets(line, cols) {
+			v.wrapped = append(v.wrapped, wrappedRow{line: i, offset: offset})
 		}
-		preview = strings.ReplaceAll(preview, "\t", "    ")
-		v.lastPreview = strings.Split(preview, "\n")
 	}
+	return v.wrapped
+}
+
+func (v *previewView) noLines(cols int) int {
+	switch {
+	case v.lastBinary != nil:
+		return v.lastBinary.noLines(cols)
+	case v.lastPreview == nil:
+		return len(v.lastEntries) + 1
+	case v.wrap:
+		return len(v.wrappedRows(cols))
+	default:
+		return len(v.lastPreview)
+	}
+}
 
-	lines := v.lastPreview
-	if v.scroll > len(lines)-p.Rows {
-		if len(lines) < p.Rows {
+func (v *previewView) Render(p term.Position) []term.Line {
+	v.update()
+	v.rows, v.cols = p.Rows, p.Cols
+
+	noLines := v.noLines(p.Cols)
+	if v.scrollToLine && v.lastPreview != nil {
+		v.scrollToLine = false
+		v.scroll = v.config.Preview.Offset.Scroll(v.lastLine, p.Rows)
+		if v.wrap {
+			v.scroll = v.firstWrappedRow(v.scroll, p.Cols)
+		}
+	}
+	if v.scroll > noLines-p.Rows {
+		if noLines < p.Rows {
 			v.scroll = 0
 		} else {
-			v.scroll = len(lines) - p.Rows
+			v.scroll = noLines - p.Rows
+		}
+	}
+	if v.hscroll > v.lastWidth-p.Cols {
+		if v.lastWidth < p.Cols {
+			v.hscroll = 0
+		} else {
+			v.hscroll = v.lastWidth - p.Cols
 		}
 	}
 
 	termLines := []term.Line{}
-	for i := v.scroll; i-v.scroll < p.Rows && i < len(lines); i++ {
-		termLine := term.NewLine(&term.Graphics{}, p.Cols)
-		termLine.AppendRaw(lines[i])
+	for i := v.scroll; i-v.scroll < p.Rows && i < noLines; i++ {
+		var termLine term.Line
+		if v.lastBinary != nil {
+			termLine = term.NewLine(&term.Graphics{}, p.Cols)
+			v.renderBinaryLine(termLine, i, p.Cols)
+		} else if v.lastPreview == nil {
+			termLine = term.NewLine(&term.Graphics{}, p.Cols)
+			v.renderDirLine(termLine, i)
+		} else if v.wrap {
+			row := v.wrappedRows(p.Cols)[i]
+			termLine = v.renderTextLine(row.line, row.offset, p.Cols)
+		} else {
+			termLine = v.renderTextLine(i, v.hscroll, p.Cols)
+		}
 		termLines = append(termLines, termLine)
 	}
 	return termLines
 }
 
-func getPreview(cmdTemplate string, path string) (string, error) {
+// renderTextLine renders a line of the text preview from the given column
+// onwards, highlighting the located line.
+func (v *previewView) renderTextLine(i int, offset int, cols int) term.Line {
+	g, ok := v.config.Graphics["preview:line"]
+	if i != v.lastLine-1 || !ok {
+		return term.NewOffsetLine(&term.Graphics{}, cols, offset).AppendRaw(v.lastPreview[i])
+	}
+	// Restore the highlight after every reset within the line.
+	text := strings.ReplaceAll(v.lastPreview[i], "\x1b[0m", "\x1b[m")
+	text = strings.ReplaceAll(text, "\x1b[m", "\x1b[m"+g.ToEscapeCode())
+	return term.NewOffsetLine(g, cols, offset).Append("", g).AppendRaw(text)
+}
+
+// firstWrappedRow returns the index of the first wrapped row of a line of the
+// text preview.
+func (v *previewView) firstWrappedRow(line int, cols int) int {
+	rows := v.wrappedRows(cols)
+	for i, row := range rows {
+		if row.line >= line {
+			return i
+		}
+	}
+	return len(rows)
+}
+
+// runPreview runs the preview command for a node. Files in archives are
+// passed to the command on its standard input, with /dev/stdin as path. They
+// are opened unless they are given.
+func runPreview(cmdTemplate string, node *filetree.FileTree, file *previewFile) ([]string, error) {
+	if !node.Virtual() {
+		preview, err := getPreview(cmdTemplate, node.AbsPath, nil)
+		return strings.Split(preview, "\n"), err
+	}
+	if file == nil {
+		var err error
+		if file, err = openPreviewFile(node); err != nil {
+			return nil, err
+		}
+		defer file.Close()
+	}
+	preview, err := getPreview(cmdTemplate, "/dev/stdin", file.reader())
+	return strings.Split(preview, "\n"), err
+}
+
+// previewFile is a file opened for the preview. Its head is kept once read,
+// so that it can be inspected by several previews.
+type previewFile struct {
+	f    fs.File
+	size int64
+	head []byte
+}
+
+func openPreviewFile(node *filetree.FileTree) (*previewFile, error) {
+	f, err := node.Open()
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &previewFile{f: f, size: info.Size()}, nil
+}
+
+// readHead reads the file until its head holds n bytes or the whole file.
+func (p *previewFile) readHead(n int) error {
+	if len(p.head) >= n {
+		return nil
+	}
+	rest, err := ioutil.ReadAll(io.LimitReader(p.f, int64(n-len(p.head))))
+	p.head = append(p.head, rest...)
+	return err
+}
+
+// reader returns the contents of the file, starting with its head. Only one
+// reader can be used.
+func (p *previewFile) reader() io.Reader {
+	return io.MultiReader(bytes.NewReader(p.head), p.f)
+}
+
+func (p *previewFile) Close() error {
+	return p.f.Close()
+}
+
+func getPreview(cmdTemplate string, path string, stdin io.Reader) (string, error) {
 	escapedPath := "\"" + strings.ReplaceAll(path, "\"", "\\\"") + "\""
 	cmd := strings.ReplaceAll(cmdTemplate, "{}", escapedPath)
 	var stdout, stderr strings.Builder
 	preview := exec.Command("bash", "-c", cmd)
+	preview.Stdin = stdin
 	preview.Stdout = &stdout
 	preview.Stderr = &stderr
 	err := preview.Run()
@@ -92,8 +327,17 @@ func getPreview(cmdTemplate string, path string) (string, error) {
 
 func (v *previewView) GetCommands() map[string]term.Command {
 	return map[string]term.Command{
-		"preview:down": v.down,
-		"preview:up":   v.up,
+		"preview:down":        v.down,
+		"preview:up":          v.up,
+		"preview:pageDown":    v.pageDown,
+		"preview:pageUp":      v.pageUp,
+		"preview:top":         v.top,
+		"preview:bottom":      v.bottom,
+		"preview:left":        v.left,
+		"preview:right":       v.right,
+		"preview:toggleWrap":  v.toggleWrap,
+		"preview:toggle":      v.toggle,
+		"preview:cycleLayout": v.cycleLayout,
 	}
 }
 
@@ -108,3 +352,76 @@ func (v *previewView) down(helper term.TerminalHelper, args ...interface{}) erro
 	v.scroll += 1
 	return nil
 }
+
+func (v *previewView) pageUp(helper term.TerminalHelper, args ...interface{}) error {
+	v.scroll -= v.rows
+	if v.scroll < 0 {
+		v.scroll = 0
+	}
+	return nil
+}
+
+func (v *previewView) pageDown(helper term.TerminalHelper, args ...interface{}) error {
+	v.scroll += v.rows
+	return nil
+}
+
+func (v *previewView) top(helper term.TerminalHelper, args ...interface{}) error {
+	v.scroll = 0
+	return nil
+}
+
+func (v *previewView) bottom(helper term.TerminalHelper, args ...interface{}) error {
+	// Clamped to the last page when rendering.
+	v.scroll = v.noLines(v.cols)
+	return nil
+}
+
+// horizontalStep returns the number of columns to scroll horizontally.
+func (v *previewView) horizontalStep() int {
+	if v.cols < 2 {
+		return 1
+	}
+	return v.cols / 2
+}
+
+func (v *previewView) left(helper term.TerminalHelper, args ...interface{}) error {
+	v.hscroll -= v.horizontalStep()
+	if v.hscroll < 0 {
+		v.hscroll = 0
+	}
+	return nil
+}
+
+func (v *previewView) right(helper term.TerminalHelper, args ...interface{}) error {
+	if !v.wrap {
+		v.hscroll += v.horizontalStep()
+	}
+	return nil
+}
+
+// toggleWrap switches between wrapping long lines and cutting them off,
+// keeping the line at the top of the preview in place.
+func (v *previewView) toggleWrap(helper term.TerminalHelper, args ...interface{}) error {
+	v.wrap = !v.wrap
+	v.hscroll = 0
+	if v.lastPreview == nil || v.cols == 0 {
+		return nil
+	}
+	if v.wrap {
+		v.scroll = v.firstWrappedRow(v.scroll, v.cols)
+	} else if rows := v.wrappedRows(v.cols); v.scroll < len(rows) {
+		v.scroll = rows[v.scroll].line
+	}
+	return nil
+}
+
+func (v *previewView) toggle(helper term.TerminalHelper, args ...interface{}) error {
+	v.layout.TogglePreview()
+	return nil
+}
+
+func (v *previewView) cycleLayout(helper term.TerminalHelper, args ...interface{}) error {
+	v.layout.CyclePreviewPosition()
+	return nil
+}
@@ -1,6 +1,8 @@
 package views
 
 import (
+	"fmt"
+
 	"github.com/wvanlint/twf/internal/config"
 	"github.com/wvanlint/twf/internal/state"
 	term "github.com/wvanlint/twf/internal/terminal"
@@ -37,7 +39,17 @@ func (v *statusView) ShouldRender() bool {
 
 func (v *statusView) Render(p term.Position) []term.Line {
 	line := term.NewLine(&term.Graphics{}, p.Cols)
-	line.Append("", &term.Graphics{})
+	if pending := v.state.Loader.Expanding(); pending > 0 {
+		line.Append(fmt.Sprintf("Loading %d directories... (esc to cancel)", pending), v.config.Graphics["tree:loading"])
+	} else if v.state.ReadingPaths {
+		line.Append("Reading paths...", v.config.Graphics["tree:loading"])
+	} else if v.state.DiskUsage != nil && v.state.DiskUsage.Running() {
+		line.Append("Computing sizes...", v.config.Graphics["tree:loading"])
+	} else if err := v.state.Cursor.ReadError(); err != nil {
+		line.Append(err.Error(), v.config.Graphics["status:error"])
+	} else {
+		line.Append("", &term.Graphics{})
+	}
 	return []term.Line{line}
 }
 
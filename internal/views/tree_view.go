@@ -1,46 +1,48 @@
 package views
 
 import (
-	"math"
 	"strings"
+	"time"
 
 	"github.com/wvanlint/twf/internal/config"
 	"github.com/wvanlint/twf/internal/filetree"
+	"github.com/wvanlint/twf/internal/git"
 	"github.com/wvanlint/twf/internal/state"
 	term "github.com/wvanlint/twf/internal/terminal"
 )
 
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+const spinnerInterval = 100 * time.Millisecond
+
+var gitGlyphs = map[git.Kind]string{
+	git.Modified:   "M",
+	git.Staged:     "S",
+	git.Untracked:  "?",
+	git.Ignored:    "!",
+	git.Conflicted: "U",
+	git.Dirty:      "•",
+}
+
 type treeView struct {
 	config     *config.TwfConfig
 	state      *state.State
-	lineByPath map[string]int
+	layout     *Layout
 	rows       int
 	scroll     int
+	sortBySize bool
 }
 
-func NewTreeView(config *config.TwfConfig, state *state.State) term.View {
+func NewTreeView(config *config.TwfConfig, state *state.State, layout *Layout) term.View {
 	return &treeView{
 		config: config,
 		state:  state,
+		layout: layout,
 	}
 }
 
 func (v *treeView) Position(totalRows int, totalCols int) term.Position {
-	if v.config.Preview.Enabled && !v.state.Cursor.IsDir() {
-		return term.Position{
-			Top:  1,
-			Left: 1,
-			Rows: totalRows - 1,
-			Cols: int(math.Ceil(float64(totalCols) / 2.0)),
-		}
-	} else {
-		return term.Position{
-			Top:  1,
-			Left: 1,
-			Rows: totalRows - 1,
-			Cols: totalCols,
-		}
-	}
+	return v.layout.Tree(totalRows, totalCols)
 }
 
 func (v *treeView) HasBorder() bool {
@@ -60,6 +62,29 @@ func (v *treeView) renderNode(
 	line.Append(strings.Repeat("  ", indentation), nil)
 
 	graphics := term.Graphics{}
+	gitStatus := git.Unmodified
+	if v.state.Git != nil {
+		gitStatus = v.state.Git.Lookup(node.AbsPath)
+	}
+	gitGraphics, hasGitGraphics := v.config.Graphics["git:"+gitStatus.String()]
+	if hasGitGraphics {
+		graphics.Merge(gitGraphics)
+	}
+	if fileColors := v.config.TreeView.FileColors; fileColors != nil {
+		if g := fileColors.Lookup(node); g != nil {
+			graphics.Merge(g)
+		}
+	}
+	if node.BrokenLink() {
+		if g, ok := v.config.Graphics["tree:brokenLink"]; ok {
+			graphics.Merge(g)
+		}
+	}
+	if node.PermissionDenied() {
+		if g, ok := v.config.Graphics["tree:permissionDenied"]; ok {
+			graphics.Merge(g)
+		}
+	}
 	if node.IsDir() {
 		if g, ok := v.config.Graphics["tree:dir"]; ok {
 			graphics.Merge(g)
@@ -71,7 +96,14 @@ func (v *treeView) renderNode(
 		}
 	}
 
-	if node.IsDir() {
+	if node.Loading() {
+		loadingGraphics := graphics
+		if g, ok := v.config.Graphics["tree:loading"]; ok {
+			loadingGraphics.Merge(g)
+		}
+		frame := time.Now().UnixNano() / int64(spinnerInterval) % int64(len(spinnerFrames))
+		line.Append(string(spinnerFrames[frame])+" ", &loadingGraphics)
+	} else if node.IsDir() {
 		if node.Expanded() {
 			line.Append("▼ ", &graphics)
 		} else {
@@ -79,29 +111,63 @@ func (v *treeView) renderNode(
 		}
 	}
 	line.Append(node.Name(), &graphics)
+	if glyph, ok := gitGlyphs[gitStatus]; ok {
+		line.Append(" "+glyph, gitGraphics)
+	}
+	if node.IsDir() && v.state.DiskUsage != nil {
+		if size, ok := v.state.DiskUsage.Size(node); ok {
+			line.Append("  "+humanizeSize(size), v.config.Graphics["tree:size"])
+		}
+	}
 	return line
 }
 
 func (v *treeView) Render(p term.Position) []term.Line {
-	lines := []term.Line{}
 	v.rows = p.Rows
-	v.lineByPath = make(map[string]int)
-	v.state.Root.Traverse(true, filetree.ByTypeAndName, func(tree *filetree.FileTree, depth int) error {
-		line := v.renderNode(tree, depth, p.Cols)
-		v.lineByPath[tree.AbsPath] = len(lines)
-		lines = append(lines, line)
-		return nil
-	})
-	v.scroll = v.scrollForPath(v.state.Cursor.AbsPath)
-	return lines[v.scroll:]
+	v.scroll = v.scrollForRow(v.state.Cursor.Row())
+	v.clampScroll()
+	lines := []term.Line{}
+	rootDepth := v.state.Root.Depth()
+	node := v.state.Root.RowAt(v.scroll)
+	for node != nil && len(lines) < p.Rows {
+		lines = append(lines, v.renderNode(node, node.Depth()-rootDepth, p.Cols))
+		next, err := node.Next(true, nil)
+		if err != nil {
+			break
+		}
+		node = next
+	}
+	return lines
 }
 
-func (v *treeView) scrollForPath(path string) int {
-	targetLine := v.lineByPath[path]
-	if targetLine < v.scroll {
-		return targetLine
-	} else if targetLine >= v.scroll+v.rows {
-		return targetLine - v.rows + 1
+// scrollOff returns the amount of lines to keep visible around the cursor,
+// limited so that the cursor can still move within the view.
+func (v *treeView) scrollOff() int {
+	scrollOff := v.config.TreeView.ScrollOff
+	if scrollOff > (v.rows-1)/2 {
+		scrollOff = (v.rows - 1) / 2
+	}
+	if scrollOff < 0 {
+		scrollOff = 0
+	}
+	return scrollOff
+}
+
+func (v *treeView) clampScroll() {
+	if v.scroll > v.state.Root.VisibleRows()-v.rows {
+		v.scroll = v.state.Root.VisibleRows() - v.rows
+	}
+	if v.scroll < 0 {
+		v.scroll = 0
+	}
+}
+
+func (v *treeView) scrollForRow(targetLine int) int {
+	scrollOff := v.scrollOff()
+	if targetLine < v.scroll+scrollOff {
+		return targetLine - scrollOff
+	} else if targetLine >= v.scroll+v.rows-scrollOff {
+		return targetLine - v.rows + 1 + scrollOff
 	} else {
 		return v.scroll
 	}
@@ -109,27 +175,80 @@ func (v *treeView) scrollForPath(path string) int {
 
 func (v *treeView) GetCommands() map[string]term.Command {
 	return map[string]term.Command{
-		"tree:prev":           v.prev,
-		"tree:next":           v.next,
-		"tree:open":           v.open,
-		"tree:close":          v.close,
-		"tree:toggle":         v.toggle,
-		"tree:toggleAll":      v.toggleAll,
-		"tree:openAll":        v.openAll,
-		"tree:closeAll":       v.closeAll,
-		"tree:parent":         v.parent,
-		"tree:locateExternal": v.locateExternal,
-		"tree:selectPath":     v.selectPath,
+		"tree:prev":             v.prev,
+		"tree:next":             v.next,
+		"tree:first":            v.first,
+		"tree:last":             v.last,
+		"tree:pageUp":           v.pageUp,
+		"tree:pageDown":         v.pageDown,
+		"tree:halfPageUp":       v.halfPageUp,
+		"tree:halfPageDown":     v.halfPageDown,
+		"tree:prevSibling":      v.prevSibling,
+		"tree:nextSibling":      v.nextSibling,
+		"tree:firstChild":       v.firstChild,
+		"tree:nextDir":          v.nextDir,
+		"tree:center":           v.center,
+		"tree:scrollTop":        v.scrollTop,
+		"tree:scrollBottom":     v.scrollBottom,
+		"tree:scrollUp":         v.scrollUp,
+		"tree:scrollDown":       v.scrollDown,
+		"tree:open":             v.open,
+		"tree:close":            v.close,
+		"tree:toggle":           v.toggle,
+		"tree:toggleAll":        v.toggleAll,
+		"tree:openAll":          v.openAll,
+		"tree:closeAll":         v.closeAll,
+		"tree:cancelLoading":    v.cancelLoading,
+		"tree:parent":           v.parent,
+		"tree:locate":           v.locate,
+		"tree:locateExternal":   v.locateExternal,
+		"tree:selectPath":       v.selectPath,
+		"git:refresh":           v.refreshGit,
+		"tree:computeSizes":     v.computeSizes,
+		"tree:toggleSortBySize": v.toggleSortBySize,
 	}
 }
 
+func (v *treeView) computeSizes(helper term.TerminalHelper, args ...interface{}) error {
+	if v.state.DiskUsage == nil {
+		return nil
+	}
+	v.state.DiskUsage.Start(v.state.Root, v.config.TreeView.SameDevice, helper.Post)
+	return nil
+}
+
+// toggleSortBySize switches between sorting by size and by name. Sizes are
+// computed first if needed.
+func (v *treeView) toggleSortBySize(helper term.TerminalHelper, args ...interface{}) error {
+	if v.state.DiskUsage == nil {
+		return nil
+	}
+	v.sortBySize = !v.sortBySize
+	if !v.sortBySize {
+		v.state.Root.SetOrder(nil)
+		return nil
+	}
+	if _, ok := v.state.DiskUsage.Size(v.state.Root); !ok && !v.state.DiskUsage.Running() {
+		v.computeSizes(helper)
+	}
+	v.state.Root.SetOrder(filetree.BySize(v.state.DiskUsage))
+	return nil
+}
+
+func (v *treeView) refreshGit(helper term.TerminalHelper, args ...interface{}) error {
+	if v.config.Git {
+		v.state.RefreshGit(helper.Post)
+	}
+	return nil
+}
+
 func (v *treeView) selectPath(helper term.TerminalHelper, args ...interface{}) error {
 	v.state.Selection = append(v.state.Selection, v.state.Cursor)
 	return nil
 }
 
 func (v *treeView) prev(helper term.TerminalHelper, args ...interface{}) error {
-	prev, err := v.state.Cursor.Prev(true, filetree.ByTypeAndName)
+	prev, err := v.state.Cursor.Prev(true, nil)
 	if err != nil {
 		return err
 	}
@@ -140,7 +259,79 @@ func (v *treeView) prev(helper term.TerminalHelper, args ...interface{}) error {
 }
 
 func (v *treeView) next(helper term.TerminalHelper, args ...interface{}) error {
-	next, err := v.state.Cursor.Next(true, filetree.ByTypeAndName)
+	next, err := v.state.Cursor.Next(true, nil)
+	if err != nil {
+		return err
+	}
+	if next != nil {
+		v.state.Cursor = next
+	}
+	return nil
+}
+
+// moveBy moves the cursor by the given amount of lines and scrolls the view
+// along with it, as when paging.
+func (v *treeView) moveBy(delta int) error {
+	target := v.state.Cursor.Row() + delta
+	if target < 0 {
+		target = 0
+	} else if target >= v.state.Root.VisibleRows() {
+		target = v.state.Root.VisibleRows() - 1
+	}
+	v.scroll += delta
+	if v.scroll < 0 {
+		v.scroll = 0
+	}
+	v.state.Cursor = v.state.Root.RowAt(target)
+	return nil
+}
+
+func (v *treeView) halfPage() int {
+	if v.rows < 2 {
+		return 1
+	}
+	return v.rows / 2
+}
+
+func (v *treeView) first(helper term.TerminalHelper, args ...interface{}) error {
+	v.state.Cursor = v.state.Root
+	return nil
+}
+
+func (v *treeView) last(helper term.TerminalHelper, args ...interface{}) error {
+	v.state.Cursor = v.state.Root.RowAt(v.state.Root.VisibleRows() - 1)
+	return nil
+}
+
+func (v *treeView) pageUp(helper term.TerminalHelper, args ...interface{}) error {
+	return v.moveBy(-v.rows)
+}
+
+func (v *treeView) pageDown(helper term.TerminalHelper, args ...interface{}) error {
+	return v.moveBy(v.rows)
+}
+
+func (v *treeView) halfPageUp(helper term.TerminalHelper, args ...interface{}) error {
+	return v.moveBy(-v.halfPage())
+}
+
+func (v *treeView) halfPageDown(helper term.TerminalHelper, args ...interface{}) error {
+	return v.moveBy(v.halfPage())
+}
+
+func (v *treeView) prevSibling(helper term.TerminalHelper, args ...interface{}) error {
+	prev, err := v.state.Cursor.PrevSibling(nil)
+	if err != nil {
+		return err
+	}
+	if prev != nil {
+		v.state.Cursor = prev
+	}
+	return nil
+}
+
+func (v *treeView) nextSibling(helper term.TerminalHelper, args ...interface{}) error {
+	next, err := v.state.Cursor.NextSibling(nil)
 	if err != nil {
 		return err
 	}
@@ -150,8 +341,89 @@ func (v *treeView) next(helper term.TerminalHelper, args ...interface{}) error {
 	return nil
 }
 
+func (v *treeView) firstChild(helper term.TerminalHelper, args ...interface{}) error {
+	if !v.state.Cursor.IsDir() {
+		return nil
+	}
+	if err := v.state.Cursor.Expand(); err != nil {
+		return err
+	}
+	children, err := v.state.Cursor.OrderedChildren()
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		v.state.Cursor = children[0]
+	}
+	return nil
+}
+
+func (v *treeView) nextDir(helper term.TerminalHelper, args ...interface{}) error {
+	node := v.state.Cursor
+	for {
+		next, err := node.Next(true, nil)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		if next.IsDir() {
+			v.state.Cursor = next
+			return nil
+		}
+		node = next
+	}
+}
+
+func (v *treeView) center(helper term.TerminalHelper, args ...interface{}) error {
+	v.scroll = v.state.Cursor.Row() - (v.rows-1)/2
+	v.clampScroll()
+	return nil
+}
+
+func (v *treeView) scrollTop(helper term.TerminalHelper, args ...interface{}) error {
+	v.scroll = v.state.Cursor.Row() - v.scrollOff()
+	v.clampScroll()
+	return nil
+}
+
+func (v *treeView) scrollBottom(helper term.TerminalHelper, args ...interface{}) error {
+	v.scroll = v.state.Cursor.Row() - v.rows + 1 + v.scrollOff()
+	v.clampScroll()
+	return nil
+}
+
+// scrollBy scrolls the view without moving the cursor, unless the cursor
+// would leave the view.
+func (v *treeView) scrollBy(delta int) error {
+	v.scroll += delta
+	v.clampScroll()
+	scrollOff := v.scrollOff()
+	totalLines := v.state.Root.VisibleRows()
+	cursorLine := v.state.Cursor.Row()
+	if v.scroll > 0 && cursorLine < v.scroll+scrollOff {
+		cursorLine = v.scroll + scrollOff
+	} else if v.scroll+v.rows < totalLines && cursorLine >= v.scroll+v.rows-scrollOff {
+		cursorLine = v.scroll + v.rows - 1 - scrollOff
+	}
+	if cursorLine >= totalLines {
+		cursorLine = totalLines - 1
+	}
+	v.state.Cursor = v.state.Root.RowAt(cursorLine)
+	return nil
+}
+
+func (v *treeView) scrollUp(helper term.TerminalHelper, args ...interface{}) error {
+	return v.scrollBy(-1)
+}
+
+func (v *treeView) scrollDown(helper term.TerminalHelper, args ...interface{}) error {
+	return v.scrollBy(1)
+}
+
 func (v *treeView) open(helper term.TerminalHelper, args ...interface{}) error {
-	return v.state.Cursor.Expand()
+	return v.state.Loader.Expand(v.state.Cursor, helper.Post)
 }
 
 func (v *treeView) close(helper term.TerminalHelper, args ...interface{}) error {
@@ -164,33 +436,37 @@ func (v *treeView) toggle(helper term.TerminalHelper, args ...interface{}) error
 		v.state.Cursor.Collapse()
 		return nil
 	} else {
-		return v.state.Cursor.Expand()
+		return v.state.Loader.Expand(v.state.Cursor, helper.Post)
 	}
 }
 
 func (v *treeView) toggleAll(helper term.TerminalHelper, args ...interface{}) error {
-	expanded := v.state.Cursor.Expanded()
-	return v.state.Cursor.Traverse(false, nil, func(tree *filetree.FileTree, _ int) error {
-		if expanded {
-			tree.Collapse()
-			return nil
-		} else {
-			return tree.Expand()
-		}
-	})
+	if v.state.Cursor.Expanded() {
+		v.state.Cursor.CollapseAll()
+		return nil
+	} else {
+		return v.state.Loader.ExpandAll(v.state.Cursor, helper.Post)
+	}
 }
 
 func (v *treeView) openAll(helper term.TerminalHelper, args ...interface{}) error {
-	return v.state.Cursor.Traverse(false, nil, func(tree *filetree.FileTree, _ int) error {
-		return tree.Expand()
-	})
+	return v.state.Loader.ExpandAll(v.state.Cursor, helper.Post)
 }
 
 func (v *treeView) closeAll(helper term.TerminalHelper, args ...interface{}) error {
-	return v.state.Cursor.Traverse(false, nil, func(tree *filetree.FileTree, _ int) error {
-		tree.Collapse()
+	v.state.Cursor.CollapseAll()
+	return nil
+}
+
+// cancelLoading stops loading directories in the background. If directories
+// were being expanded, the remaining commands are skipped. Directories read
+// for the preview alone don't count, so that escape still quits then.
+func (v *treeView) cancelLoading(helper term.TerminalHelper, args ...interface{}) error {
+	if v.state.Loader.Expanding() == 0 {
 		return nil
-	})
+	}
+	v.state.Loader.Cancel()
+	return term.ErrSkipCommands
 }
 
 func (v *treeView) parent(helper term.TerminalHelper, args ...interface{}) error {
@@ -201,6 +477,24 @@ func (v *treeView) parent(helper term.TerminalHelper, args ...interface{}) error
 	return nil
 }
 
+// locate locates the path given as argument, such as pasted text. Paths which
+// aren't found are ignored.
+func (v *treeView) locate(helper term.TerminalHelper, args ...interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	path, ok := args[0].(string)
+	if !ok {
+		return nil
+	}
+	// Only the first line of the text is used.
+	path = strings.TrimSpace(strings.SplitN(strings.TrimSpace(path), "\n", 2)[0])
+	if path != "" {
+		v.state.LocatePath(path)
+	}
+	return nil
+}
+
 func (v *treeView) locateExternal(helper term.TerminalHelper, args ...interface{}) error {
 	content, err := helper.ExecuteInTerminal(v.config.TreeView.LocateCommand)
 	if err != nil {
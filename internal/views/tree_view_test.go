@@ -0,0 +1,293 @@
+package views
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wvanlint/twf/internal/config"
+	"github.com/wvanlint/twf/internal/filetree"
+	"github.com/wvanlint/twf/internal/state"
+	term "github.com/wvanlint/twf/internal/terminal"
+)
+
+func createTestTree(b *testing.B, dirs int, filesPerDir int) string {
+	b.Helper()
+	root, err := ioutil.TempDir("", "twf_")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < dirs; i++ {
+		dir := filepath.Join(root, fmt.Sprint("dir", i))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < filesPerDir; j++ {
+			f, err := os.Create(filepath.Join(dir, fmt.Sprint("file", j)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	}
+	return root
+}
+
+func BenchmarkTreeViewRender(b *testing.B) {
+	for _, dirs := range []int{10, 100, 1000} {
+		root := createTestTree(b, dirs, 100)
+		defer os.RemoveAll(root)
+		tree, err := filetree.InitFileTree(root)
+		if err != nil {
+			b.Fatal(err)
+		}
+		s := &state.State{Root: tree, Cursor: tree}
+		if err := s.AutoExpand(-1, nil); err != nil {
+			b.Fatal(err)
+		}
+		s.Cursor = tree.RowAt(tree.VisibleRows() / 2)
+		c := &config.TwfConfig{}
+		view := NewTreeView(c, s, NewLayout(c))
+		p := term.Position{Top: 1, Left: 1, Rows: 50, Cols: 80}
+
+		b.Run(fmt.Sprint(tree.VisibleRows(), "_rows"), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				view.Render(p)
+			}
+		})
+	}
+}
+
+func TestLocatePastedPath(t *testing.T) {
+	fsys := filetree.NewMemFS()
+	fsys.AddFile("src/main.go", "package main")
+	fsys.AddFile("README", "")
+	tree, err := filetree.InitFileTreeFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &state.State{Root: tree, Cursor: tree}
+	c := &config.TwfConfig{}
+	view := NewTreeView(c, s, NewLayout(c))
+	locate := view.GetCommands()["tree:locate"]
+
+	if err := locate(nil, "  src/main.go:3\nREADME\n"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor.Name() != "main.go" || s.Line != 3 {
+		t.Errorf("Expected cursor at main.go:3, got %s:%d", s.Cursor.Name(), s.Line)
+	}
+	// Paths which aren't found are ignored.
+	if err := locate(nil, "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Cursor.Name() != "main.go" {
+		t.Errorf("Expected cursor at main.go, got %s", s.Cursor.Name())
+	}
+}
+
+func TestRenderFileColors(t *testing.T) {
+	fsys := filetree.NewMemFS()
+	fsys.AddFile("archive.tar", "")
+	fsys.AddSymlink("broken", "missing")
+	fsys.AddFile("private/file", "")
+	fsys.SetMode("private", fs.ModeDir|0300)
+	tree, err := filetree.InitFileTreeFS(fsys, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &state.State{Root: tree, Cursor: tree}
+	if err := s.AutoExpand(2, nil); err != nil {
+		t.Fatal(err)
+	}
+	c := &config.TwfConfig{}
+	c.Graphics = config.GraphicsMapping{
+		"tree:brokenLink":       &term.Graphics{Strikethrough: true},
+		"tree:permissionDenied": &term.Graphics{Dim: true},
+	}
+	c.TreeView.FileColors = config.ParseLSColors("*.tar=31")
+	view := NewTreeView(c, s, NewLayout(c))
+
+	expected := map[string]string{
+		"archive.tar": "\x1b[31marchive.tar",
+		"broken":      "\x1b[9mbroken",
+		"private":     "\x1b[2m▼ ",
+	}
+	for name, graphics := range expected {
+		node, err := tree.FindPath(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := view.(*treeView).renderNode(node, 1, 40).Text()
+		if !strings.Contains(text, graphics) {
+			t.Errorf("Expected %q in %q", graphics, text)
+		}
+	}
+}
+
+// newNavigationView creates a tree view of rows lines on a tree with a
+// directory "dir" containing one file, followed by the given amount of files.
+func newNavigationView(t *testing.T, files int, rows int, scrollOff int) (*treeView, *state.State) {
+	t.Helper()
+	root, err := ioutil.TempDir("", "twf_")
+	assert.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(root) })
+	assert.Nil(t, os.Mkdir(filepath.Join(root, "dir"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(root, "dir", "inner"), nil, 0644))
+	for i := 0; i < files; i++ {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(root, fmt.Sprintf("file%02d", i)), nil, 0644))
+	}
+	tree, err := filetree.InitFileTree(root)
+	assert.Nil(t, err)
+	s := &state.State{Root: tree, Cursor: tree}
+	assert.Nil(t, s.AutoExpand(1, nil))
+	c := &config.TwfConfig{}
+	c.TreeView.ScrollOff = scrollOff
+	view := NewTreeView(c, s, NewLayout(c)).(*treeView)
+	view.Render(term.Position{Top: 1, Left: 1, Rows: rows, Cols: 40})
+	return view, s
+}
+
+func runTreeCommand(t *testing.T, view *treeView, name string) {
+	t.Helper()
+	assert.Nil(t, view.GetCommands()[name](nil))
+}
+
+func assertCursorRow(t *testing.T, view *treeView, row int) {
+	t.Helper()
+	assert.Equal(t, row, view.state.Cursor.Row(), view.state.Cursor.Name())
+}
+
+func TestFirstLast(t *testing.T) {
+	// Rows: ".", "dir", "file00" to "file09".
+	view, _ := newNavigationView(t, 10, 5, 0)
+	runTreeCommand(t, view, "tree:last")
+	assertCursorRow(t, view, 11)
+	runTreeCommand(t, view, "tree:last")
+	assertCursorRow(t, view, 11)
+	runTreeCommand(t, view, "tree:first")
+	assertCursorRow(t, view, 0)
+	runTreeCommand(t, view, "tree:first")
+	assertCursorRow(t, view, 0)
+}
+
+func TestPaging(t *testing.T) {
+	view, _ := newNavigationView(t, 10, 5, 0)
+	runTreeCommand(t, view, "tree:pageUp")
+	assertCursorRow(t, view, 0)
+	assert.Equal(t, 0, view.scroll)
+	runTreeCommand(t, view, "tree:pageDown")
+	assertCursorRow(t, view, 5)
+	runTreeCommand(t, view, "tree:halfPageDown")
+	assertCursorRow(t, view, 7)
+	runTreeCommand(t, view, "tree:pageDown")
+	assertCursorRow(t, view, 11)
+	runTreeCommand(t, view, "tree:pageDown")
+	assertCursorRow(t, view, 11)
+	runTreeCommand(t, view, "tree:halfPageUp")
+	assertCursorRow(t, view, 9)
+}
+
+func TestPagingShortView(t *testing.T) {
+	view, _ := newNavigationView(t, 10, 1, 0)
+	runTreeCommand(t, view, "tree:pageDown")
+	assertCursorRow(t, view, 1)
+	runTreeCommand(t, view, "tree:halfPageDown")
+	assertCursorRow(t, view, 2)
+	runTreeCommand(t, view, "tree:halfPageUp")
+	assertCursorRow(t, view, 1)
+}
+
+func TestNextDirAndFirstChild(t *testing.T) {
+	view, s := newNavigationView(t, 3, 5, 0)
+	runTreeCommand(t, view, "tree:nextDir")
+	assertCursorRow(t, view, 1)
+	// No directory follows.
+	runTreeCommand(t, view, "tree:nextDir")
+	assertCursorRow(t, view, 1)
+
+	runTreeCommand(t, view, "tree:firstChild")
+	assert.Equal(t, "inner", s.Cursor.Name())
+	// Files have no children.
+	runTreeCommand(t, view, "tree:firstChild")
+	assert.Equal(t, "inner", s.Cursor.Name())
+}
+
+func TestScrollBy(t *testing.T) {
+	// Rows: ".", "dir", "file00" to "file09".
+	view, _ := newNavigationView(t, 10, 5, 0)
+	runTreeCommand(t, view, "tree:scrollDown")
+	assert.Equal(t, 1, view.scroll)
+	assertCursorRow(t, view, 1)
+	runTreeCommand(t, view, "tree:scrollDown")
+	runTreeCommand(t, view, "tree:scrollDown")
+	assert.Equal(t, 3, view.scroll)
+	assertCursorRow(t, view, 3)
+	// The cursor stays where it is while it is visible.
+	runTreeCommand(t, view, "tree:scrollUp")
+	assert.Equal(t, 2, view.scroll)
+	assertCursorRow(t, view, 3)
+	for i := 0; i < 10; i++ {
+		runTreeCommand(t, view, "tree:scrollDown")
+	}
+	assert.Equal(t, 7, view.scroll)
+	assertCursorRow(t, view, 7)
+
+	view, _ = newNavigationView(t, 10, 5, 1)
+	runTreeCommand(t, view, "tree:scrollDown")
+	assert.Equal(t, 1, view.scroll)
+	assertCursorRow(t, view, 2)
+}
+
+func TestScrollOffClamped(t *testing.T) {
+	for _, c := range []struct {
+		rows      int
+		scrollOff int
+	}{{5, 2}, {4, 1}, {1, 0}} {
+		view, _ := newNavigationView(t, 10, c.rows, 3)
+		assert.Equal(t, c.scrollOff, view.scrollOff(), "%d rows", c.rows)
+	}
+
+	// The cursor can still reach the first row.
+	view, _ := newNavigationView(t, 10, 5, 3)
+	runTreeCommand(t, view, "tree:next")
+	view.Render(term.Position{Top: 1, Left: 1, Rows: 5, Cols: 40})
+	assertCursorRow(t, view, 1)
+	assert.Equal(t, 0, view.scroll)
+}
+
+func TestScrollCursorToPosition(t *testing.T) {
+	view, s := newNavigationView(t, 10, 5, 0)
+	runTreeCommand(t, view, "tree:next")
+	runTreeCommand(t, view, "tree:center")
+	assert.Equal(t, 0, view.scroll)
+	runTreeCommand(t, view, "tree:scrollBottom")
+	assert.Equal(t, 0, view.scroll)
+
+	runTreeCommand(t, view, "tree:last")
+	runTreeCommand(t, view, "tree:center")
+	assert.Equal(t, 7, view.scroll)
+	runTreeCommand(t, view, "tree:scrollTop")
+	assert.Equal(t, 7, view.scroll)
+	assertCursorRow(t, view, 11)
+
+	s.Cursor = s.Root.RowAt(5)
+	runTreeCommand(t, view, "tree:center")
+	assert.Equal(t, 3, view.scroll)
+	runTreeCommand(t, view, "tree:scrollTop")
+	assert.Equal(t, 5, view.scroll)
+	runTreeCommand(t, view, "tree:scrollBottom")
+	assert.Equal(t, 1, view.scroll)
+}
+
+func TestSizesWithoutDiskUsage(t *testing.T) {
+	view, _ := newNavigationView(t, 2, 5, 0)
+	runTreeCommand(t, view, "tree:computeSizes")
+	runTreeCommand(t, view, "tree:toggleSortBySize")
+	assert.False(t, view.sortBySize)
+}